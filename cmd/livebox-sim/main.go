@@ -0,0 +1,35 @@
+// Command livebox-sim runs a simulated Livebox, the exporter can be pointed at
+// it using the LIVEBOX_ADDRESS environment variable.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Tomy2e/livebox-exporter/internal/liveboxsim"
+)
+
+func main() {
+	listen := flag.String("listen", ":8081", "Listening address")
+	scenarioFile := flag.String("scenario", "", "Optional path to a JSON scenario file, a Livebox 5 is simulated if empty")
+	tick := flag.Duration("tick", time.Second, "Interval between each step of the simulation")
+	flag.Parse()
+
+	scenario := liveboxsim.DefaultScenario()
+
+	if *scenarioFile != "" {
+		var err error
+		if scenario, err = liveboxsim.LoadScenario(*scenarioFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	sim := liveboxsim.New(scenario)
+	go sim.Run(context.Background(), *tick)
+
+	log.Printf("Simulating %s on %s\n", scenario.Info.ProductClass, *listen)
+	log.Fatal(http.ListenAndServe(*listen, sim))
+}
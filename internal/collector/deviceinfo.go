@@ -6,14 +6,14 @@ import (
 	"sync"
 	"time"
 
-	"github.com/Tomy2e/livebox-api-client"
 	"github.com/Tomy2e/livebox-api-client/api/request"
+	exporterLivebox "github.com/Tomy2e/livebox-exporter/pkg/livebox"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 // DeviceInfo implements a prometheus Collector that returns Livebox specific metrics.
 type DeviceInfo struct {
-	client                *livebox.Client
+	client                exporterLivebox.API
 	numberOfRebootsMetric *prometheus.Desc
 	uptimeMetric          *prometheus.Desc
 	memoryTotalMetric     *prometheus.Desc
@@ -21,7 +21,7 @@ type DeviceInfo struct {
 }
 
 // NewDeviceInfo returns a new DeviceInfo collector using the specified client.
-func NewDeviceInfo(client *livebox.Client) *DeviceInfo {
+func NewDeviceInfo(client exporterLivebox.API) *DeviceInfo {
 	return &DeviceInfo{
 		client: client,
 		numberOfRebootsMetric: prometheus.NewDesc(
@@ -4,39 +4,156 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/Tomy2e/livebox-api-client"
 	"github.com/Tomy2e/livebox-api-client/api/request"
 	"github.com/Tomy2e/livebox-exporter/pkg/bitrate"
 	exporterLivebox "github.com/Tomy2e/livebox-exporter/pkg/livebox"
+	"github.com/Tomy2e/livebox-exporter/pkg/oui"
 	"github.com/go-viper/mapstructure/v2"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 type Devices struct {
-	client                       *livebox.Client
+	ctx                          context.Context
+	client                       exporterLivebox.API
 	deviceRates                  sync.Map
 	wifiDeviceRates              sync.Map
+	traffic                      *deviceTraffic
+	presence                     *presence
+	trafficFile                  string
+	ouiDatabase                  *oui.Database
+	config                       *DevicesConfig
+	stationStatsInterval         time.Duration
+	ratesTTL                     time.Duration
+	stateTTL                     time.Duration
+	seriesDropped                prometheus.Gauge
 	deviceActive                 *prometheus.Desc
+	deviceInfo                   *prometheus.Desc
+	deviceIPv6Addresses          *prometheus.Desc
+	deviceFirstSeen              *prometheus.Desc
+	deviceLastConnection         *prometheus.Desc
 	deviceRxMbits, deviceTxMbits *prometheus.Desc
+	deviceRxBytes, deviceTxBytes *prometheus.Desc
+	deviceConnections            *prometheus.Desc
+	deviceLastSeen               *prometheus.Desc
+	deviceSessionDuration        *prometheus.Desc
+	othersActive                 *prometheus.Desc
+}
+
+// DevicesOption configures the Devices collector.
+type DevicesOption func(*Devices)
+
+// WithContext sets the context of the background goroutines of the collector,
+// they stop when the context is canceled.
+func WithContext(ctx context.Context) DevicesOption {
+	return func(d *Devices) {
+		d.ctx = ctx
+	}
+}
+
+// WithTrafficFile persists the accumulated traffic of devices in the specified
+// file, so that totals survive restarts of the exporter.
+func WithTrafficFile(path string) DevicesOption {
+	return func(d *Devices) {
+		d.trafficFile = path
+	}
 }
 
 type rates struct {
 	Tx, Rx float64
+	// updated is the time when the rates were last updated.
+	updated time.Time
+}
+
+// WithConfig configures which devices are exported, see DevicesConfig.
+func WithConfig(config *DevicesConfig) DevicesOption {
+	return func(d *Devices) {
+		d.config = config
+	}
+}
+
+// WithOUIDatabase uses the specified OUI database to find the vendor of devices
+// instead of the database embedded in the binary.
+func WithOUIDatabase(db *oui.Database) DevicesOption {
+	return func(d *Devices) {
+		d.ouiDatabase = db
+	}
+}
+
+// WithStationStatsInterval sets the interval between each poll of the Wi-Fi
+// station stats, used to compute the rates of Wi-Fi devices.
+func WithStationStatsInterval(interval time.Duration) DevicesOption {
+	return func(d *Devices) {
+		d.stationStatsInterval = interval
+	}
 }
 
-func NewDevices(client *livebox.Client, interfaces []*exporterLivebox.Interface) *Devices {
+// WithRatesTTL sets the duration after which the rates of a device that are no
+// longer updated are removed.
+func WithRatesTTL(ttl time.Duration) DevicesOption {
+	return func(d *Devices) {
+		d.ratesTTL = ttl
+	}
+}
+
+// WithStateTTL sets the duration after which the accumulated traffic and the
+// presence of a device that is no longer seen are forgotten.
+func WithStateTTL(ttl time.Duration) DevicesOption {
+	return func(d *Devices) {
+		d.stateTTL = ttl
+	}
+}
+
+func NewDevices(client exporterLivebox.API, interfaces []*exporterLivebox.Interface, opts ...DevicesOption) *Devices {
 	d := &Devices{
-		client: client,
+		ctx:         context.Background(),
+		client:      client,
+		traffic:     newDeviceTraffic(),
+		presence:    newPresence(),
+		ouiDatabase: oui.Default(),
+		config:      &DevicesConfig{},
+		// Poll station stats every 5 seconds by default.
+		stationStatsInterval: 5 * time.Second,
+		ratesTTL:             2 * time.Minute,
+		stateTTL:             24 * time.Hour,
+		seriesDropped: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "livebox_device_series_dropped",
+			Help: "Number of devices currently not exported because the maximum number of device series was reached.",
+		}),
 		deviceActive: prometheus.NewDesc(
 			"livebox_device_active",
 			"Status of the device.",
 			[]string{"name", "type", "mac"},
 			nil,
 		),
+		deviceInfo: prometheus.NewDesc(
+			"livebox_device_info",
+			"Information about the device.",
+			[]string{"name", "type", "mac", "ipv4", "interface", "ssid", "layer", "band", "vendor", "random_mac", "owner", "room", "group"},
+			nil,
+		),
+		deviceIPv6Addresses: prometheus.NewDesc(
+			"livebox_device_ipv6_addresses",
+			"Number of IPv6 addresses of the device.",
+			[]string{"name", "type", "mac"},
+			nil,
+		),
+		deviceFirstSeen: prometheus.NewDesc(
+			"livebox_device_first_seen_timestamp_seconds",
+			"Time when the device was first seen by the Livebox.",
+			[]string{"name", "type", "mac"},
+			nil,
+		),
+		deviceLastConnection: prometheus.NewDesc(
+			"livebox_device_last_connection_timestamp_seconds",
+			"Time when the device last connected to the Livebox.",
+			[]string{"name", "type", "mac"},
+			nil,
+		),
 		deviceRxMbits: prometheus.NewDesc(
 			"livebox_device_rx_mbits",
 			"Received Mbits per second by device.",
@@ -49,6 +166,54 @@ func NewDevices(client *livebox.Client, interfaces []*exporterLivebox.Interface)
 			[]string{"name", "type", "mac", "source"},
 			nil,
 		),
+		deviceRxBytes: prometheus.NewDesc(
+			"livebox_device_rx_bytes_total",
+			"Received bytes by device.",
+			[]string{"name", "type", "mac"},
+			nil,
+		),
+		deviceTxBytes: prometheus.NewDesc(
+			"livebox_device_tx_bytes_total",
+			"Transmitted bytes by device.",
+			[]string{"name", "type", "mac"},
+			nil,
+		),
+		deviceConnections: prometheus.NewDesc(
+			"livebox_device_connections_total",
+			"Number of times the device connected to the Livebox.",
+			[]string{"name", "type", "mac"},
+			nil,
+		),
+		deviceLastSeen: prometheus.NewDesc(
+			"livebox_device_last_seen_timestamp_seconds",
+			"Last time the device was seen connected to the Livebox.",
+			[]string{"name", "type", "mac"},
+			nil,
+		),
+		deviceSessionDuration: prometheus.NewDesc(
+			"livebox_device_session_duration_seconds",
+			"Duration of the current session of the device, or of the last session if the device is not active.",
+			[]string{"name", "type", "mac"},
+			nil,
+		),
+		othersActive: prometheus.NewDesc(
+			"livebox_devices_other_active",
+			"Number of active devices aggregated into the \"other\" device.",
+			nil,
+			nil,
+		),
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	if d.trafficFile != "" {
+		if err := d.traffic.load(d.trafficFile); err != nil {
+			log.Printf("WARN: Devices collector: %s", err)
+		}
+
+		go d.traffic.startSaving(d.ctx, d.trafficFile)
 	}
 
 	go d.startEventsObserver()
@@ -58,19 +223,27 @@ func NewDevices(client *livebox.Client, interfaces []*exporterLivebox.Interface)
 }
 
 func (d *Devices) startEventsObserver() {
-	br := bitrate.New(0)
-	events := d.client.Events(context.TODO(), []string{"Devices.Device"})
+	br := bitrate.New()
+	expired := time.Now()
+	events := d.client.Events(d.ctx, []string{"Devices.Device"})
 
 	for evt := range events {
+		// Remove the measures of disconnected devices from time to time.
+		if time.Since(expired) > d.ratesTTL {
+			br.Expire(d.ratesTTL)
+			expired = time.Now()
+		}
+
 		if evt.Error != nil {
 			log.Printf("WARN: event error: %s", evt.Error)
 			continue
 		}
-		if evt.Event.Object.Reason != "Statistics" {
+		if evt.Event.Reason != "Statistics" {
+			d.presence.handleEvent(evt.Event.Handler, evt.Event.Reason, evt.Event.Attributes)
 			continue
 		}
 
-		for mac, attr := range evt.Event.Object.Attributes {
+		for mac, attr := range evt.Event.Attributes {
 			var ds struct {
 				RxBytes uint64
 				TxBytes uint64
@@ -80,15 +253,22 @@ func (d *Devices) startEventsObserver() {
 				continue
 			}
 
-			bitrates := br.Measure(mac, &bitrate.Counters{
+			d.presence.seen(mac)
+
+			counters := &bitrate.Counters{
 				Tx: ds.TxBytes,
 				Rx: ds.RxBytes,
-			})
+			}
+
+			d.traffic.addEvents(mac, counters)
+
+			bitrates := br.Measure(mac, counters)
 
 			if bitrates.Rx != nil && bitrates.Tx != nil {
 				d.deviceRates.Store(mac, &rates{
-					Tx: bitrates.Tx.Value,
-					Rx: bitrates.Rx.Value,
+					Tx:      bitrates.Tx.Value,
+					Rx:      bitrates.Rx.Value,
+					updated: time.Now(),
 				})
 			}
 
@@ -97,7 +277,7 @@ func (d *Devices) startEventsObserver() {
 }
 
 func (d *Devices) startStationStatsPoller(interfaces []*exporterLivebox.Interface) {
-	br := bitrate.New(0)
+	br := bitrate.New()
 
 	for {
 		for _, itf := range interfaces {
@@ -114,42 +294,58 @@ func (d *Devices) startStationStatsPoller(interfaces []*exporterLivebox.Interfac
 				} `json:"status"`
 			}
 
-			if err := d.client.Request(context.TODO(), request.New(
+			if err := d.client.Request(d.ctx, request.New(
 				fmt.Sprintf("NeMo.Intf.%s", itf.Name),
 				"getStationStats",
 				nil,
 			), &stats); err != nil {
+				if d.ctx.Err() != nil {
+					return
+				}
+
 				log.Printf("WARN: getStationStats error: %s", err)
 				continue
 			}
 
 			for _, stationStats := range stats.Status {
-				bitrates := br.Measure(stationStats.MACAddress, &bitrate.Counters{
+				counters := &bitrate.Counters{
 					// Tx and Rx are swapped here.
 					Tx: stationStats.RxBytes,
 					Rx: stationStats.TxBytes,
-				})
+				}
+
+				d.traffic.addStationStats(stationStats.MACAddress, counters)
 
-				// Load existing rates or initialize.
-				r, ok := d.wifiDeviceRates.Load(stationStats.MACAddress)
-				if !ok {
-					r = &rates{}
+				bitrates := br.Measure(stationStats.MACAddress, counters)
+
+				// Copy existing rates or initialize.
+				r := &rates{}
+				if last, ok := d.wifiDeviceRates.Load(stationStats.MACAddress); ok {
+					*r = *last.(*rates)
 				}
 
 				if bitrates.Rx != nil && !bitrates.Rx.Reset {
-					r.(*rates).Rx = bitrates.Rx.Value
+					r.Rx = bitrates.Rx.Value
 				}
 
 				if bitrates.Tx != nil && !bitrates.Tx.Reset {
-					r.(*rates).Tx = bitrates.Tx.Value
+					r.Tx = bitrates.Tx.Value
 				}
 
+				r.updated = time.Now()
+
 				// Persist rates.
 				d.wifiDeviceRates.Store(stationStats.MACAddress, r)
 			}
 		}
 
-		time.Sleep(5 * time.Second)
+		br.Expire(d.ratesTTL)
+
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-time.After(d.stationStatsInterval):
+		}
 	}
 }
 
@@ -161,16 +357,11 @@ func (d *Devices) Collect(c chan<- prometheus.Metric) {
 	defer warnOnSlowCollect(d, time.Now())
 
 	var devices struct {
-		Status []struct {
-			Key        string `json:"Key"`
-			Name       string `json:"Name"`
-			DeviceType string `json:"DeviceType"`
-			Active     bool   `json:"Active"`
-		} `json:"status"`
+		Status []*device `json:"status"`
 	}
 
 	if err := d.client.Request(
-		context.TODO(),
+		d.ctx,
 		request.New("Devices", "get", request.Parameters{"expression": ".DeviceType!=\"\" and .DeviceType!=\"SAH HGW\""}),
 		&devices,
 	); err != nil {
@@ -178,61 +369,161 @@ func (d *Devices) Collect(c chan<- prometheus.Metric) {
 		return
 	}
 
-	for _, device := range devices.Status {
-		// Quick check to skip devices without a MAC address.
-		if !strings.Contains(device.Key, ":") {
-			continue
-		}
+	// Quick check to skip devices without a MAC address.
+	devices.Status = slices.DeleteFunc(devices.Status, func(dev *device) bool {
+		return !strings.Contains(dev.Key, ":")
+	})
 
-		var active float64
-		if device.Active {
-			active = 1
-		}
+	d.evictRates()
+	d.traffic.expire(d.stateTTL)
+	d.presence.sync(devices.Status, d.stateTTL)
+	d.config.applyAliases(devices.Status)
 
-		c <- prometheus.MustNewConstMetric(
-			d.deviceActive,
-			prometheus.GaugeValue,
-			active,
-			device.Name,
-			device.DeviceType,
-			device.Key,
-		)
+	selected, others, dropped := d.config.selectDevices(devices.Status)
 
-		// Skip sending other metrics if device is not active.
-		if !device.Active {
-			continue
-		}
+	for _, device := range selected {
+		d.collectDevice(c, device)
+	}
 
-		// Try to get wifi rates first as they're more accurate.
-		source := "stationStats"
-		r, ok := d.wifiDeviceRates.Load(device.Key)
-		if !ok {
-			// Try to get rates obtained through events (less accurate).
-			r, ok = d.deviceRates.Load(device.Key)
-			if !ok {
-				// Skip if no rates found.
-				continue
+	if d.config.AggregateOthers && len(others) > 0 {
+		d.collectOthers(c, others)
+	}
+
+	d.seriesDropped.Set(float64(dropped))
+	d.seriesDropped.Collect(c)
+	d.presence.newDevices.Collect(c)
+}
+
+// evictRates removes the rates of the devices that were not updated within
+// the rates TTL, for example devices that are disconnected.
+func (d *Devices) evictRates() {
+	deadline := time.Now().Add(-d.ratesTTL)
+
+	for _, m := range []*sync.Map{&d.wifiDeviceRates, &d.deviceRates} {
+		m.Range(func(mac, r any) bool {
+			if r.(*rates).updated.Before(deadline) {
+				m.CompareAndDelete(mac, r)
 			}
-			source = "events"
-		}
 
+			return true
+		})
+	}
+}
+
+// rates returns the current rates of a device and their source.
+func (d *Devices) rates(mac string) (*rates, string, bool) {
+	// Try to get wifi rates first as they're more accurate.
+	if r, ok := d.wifiDeviceRates.Load(mac); ok {
+		return r.(*rates), "stationStats", true
+	}
+
+	// Try to get rates obtained through events (less accurate).
+	if r, ok := d.deviceRates.Load(mac); ok {
+		return r.(*rates), "events", true
+	}
+
+	return nil, "", false
+}
+
+// collectDevice sends all metrics of a device.
+func (d *Devices) collectDevice(c chan<- prometheus.Metric, device *device) {
+	var active float64
+	if device.Active {
+		active = 1
+	}
+
+	c <- prometheus.MustNewConstMetric(
+		d.deviceActive,
+		prometheus.GaugeValue,
+		active,
+		device.Name,
+		device.DeviceType,
+		device.Key,
+	)
+
+	d.collectInfo(c, device)
+	d.collectPresence(c, device)
+
+	// Accumulated traffic is sent even if the device is not active.
+	if total, ok := d.traffic.get(device.Key); ok {
 		c <- prometheus.MustNewConstMetric(
-			d.deviceRxMbits,
-			prometheus.GaugeValue,
-			float64(r.(*rates).Rx),
+			d.deviceRxBytes,
+			prometheus.CounterValue,
+			float64(total.Rx),
 			device.Name,
 			device.DeviceType,
 			device.Key,
-			source,
 		)
 		c <- prometheus.MustNewConstMetric(
-			d.deviceTxMbits,
-			prometheus.GaugeValue,
-			float64(r.(*rates).Tx),
+			d.deviceTxBytes,
+			prometheus.CounterValue,
+			float64(total.Tx),
 			device.Name,
 			device.DeviceType,
 			device.Key,
-			source,
 		)
 	}
+
+	// Skip sending other metrics if device is not active.
+	if !device.Active {
+		return
+	}
+
+	r, source, ok := d.rates(device.Key)
+	if !ok {
+		// Skip if no rates found.
+		return
+	}
+
+	c <- prometheus.MustNewConstMetric(
+		d.deviceRxMbits,
+		prometheus.GaugeValue,
+		float64(r.Rx),
+		device.Name,
+		device.DeviceType,
+		device.Key,
+		source,
+	)
+	c <- prometheus.MustNewConstMetric(
+		d.deviceTxMbits,
+		prometheus.GaugeValue,
+		float64(r.Tx),
+		device.Name,
+		device.DeviceType,
+		device.Key,
+		source,
+	)
+}
+
+// collectOthers sends the metrics of devices that are not exported
+// individually, aggregated into a single "other" device.
+func (d *Devices) collectOthers(c chan<- prometheus.Metric, others []*device) {
+	var (
+		active float64
+		rates  rates
+		macs   = make([]string, 0, len(others))
+	)
+
+	for _, device := range others {
+		macs = append(macs, device.Key)
+
+		if !device.Active {
+			continue
+		}
+
+		active++
+
+		if r, _, ok := d.rates(device.Key); ok {
+			rates.Rx += r.Rx
+			rates.Tx += r.Tx
+		}
+	}
+
+	total := d.traffic.aggregate(macs)
+
+	c <- prometheus.MustNewConstMetric(d.othersActive, prometheus.GaugeValue, active)
+	c <- prometheus.MustNewConstMetric(d.deviceRxBytes, prometheus.CounterValue, float64(total.Rx), otherDevice, otherDevice, otherDevice)
+	c <- prometheus.MustNewConstMetric(d.deviceTxBytes, prometheus.CounterValue, float64(total.Tx), otherDevice, otherDevice, otherDevice)
+	c <- prometheus.MustNewConstMetric(d.deviceRxMbits, prometheus.GaugeValue, rates.Rx, otherDevice, otherDevice, otherDevice, "aggregated")
+	c <- prometheus.MustNewConstMetric(d.deviceTxMbits, prometheus.GaugeValue, rates.Tx, otherDevice, otherDevice, otherDevice, "aggregated")
 }
@@ -0,0 +1,174 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/Tomy2e/livebox-exporter/pkg/oui"
+)
+
+// otherDevice is the name, type and MAC of the device aggregating the devices
+// that are not exported individually.
+const otherDevice = "other"
+
+// DevicesConfig configures which devices are exported by the Devices collector.
+type DevicesConfig struct {
+	// Include lists the devices to export. All devices are exported if empty.
+	Include []*DeviceRule `json:"include"`
+	// Exclude lists the devices not to export.
+	Exclude []*DeviceRule `json:"exclude"`
+	// MaxSeries is the maximum number of devices exported individually, active
+	// devices are preferred. 0 means no limit.
+	MaxSeries int `json:"maxSeries"`
+	// AggregateOthers aggregates the devices that are not exported
+	// individually into an "other" device.
+	AggregateOthers bool `json:"aggregateOthers"`
+	// Aliases contains static information about devices, indexed by MAC.
+	Aliases map[string]*DeviceAlias `json:"aliases"`
+}
+
+// DeviceAlias contains static information about a device.
+type DeviceAlias struct {
+	// Name overrides the name of the device returned by the Livebox.
+	Name string `json:"name"`
+	// Owner of the device.
+	Owner string `json:"owner"`
+	// Room where the device is located.
+	Room string `json:"room"`
+	// Group of the device.
+	Group string `json:"group"`
+}
+
+// DeviceRule matches devices. All non-empty fields of a rule must match.
+type DeviceRule struct {
+	// MAC address of the device (case insensitive).
+	MAC string `json:"mac"`
+	// Name is a regular expression matched against the name of the device.
+	Name string `json:"name"`
+	// Type of the device.
+	Type string `json:"type"`
+	// Interface or SSID the device is connected to.
+	Interface string `json:"interface"`
+	// RandomMAC matches devices with (or without) a locally administered MAC.
+	RandomMAC *bool `json:"randomMac"`
+
+	name *regexp.Regexp
+}
+
+// LoadDevicesConfig loads the Devices collector configuration from a JSON file.
+func LoadDevicesConfig(path string) (*DevicesConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read devices config: %w", err)
+	}
+
+	config := &DevicesConfig{}
+	if err := json.Unmarshal(b, config); err != nil {
+		return nil, fmt.Errorf("failed to decode devices config: %w", err)
+	}
+
+	for _, rule := range append(slices.Clone(config.Include), config.Exclude...) {
+		if rule.Name == "" {
+			continue
+		}
+
+		if rule.name, err = regexp.Compile(rule.Name); err != nil {
+			return nil, fmt.Errorf("invalid device name regular expression: %w", err)
+		}
+	}
+
+	// MAC addresses returned by the Livebox are uppercase.
+	aliases := make(map[string]*DeviceAlias, len(config.Aliases))
+	for mac, alias := range config.Aliases {
+		aliases[strings.ToUpper(mac)] = alias
+	}
+	config.Aliases = aliases
+
+	if config.MaxSeries < 0 {
+		return nil, fmt.Errorf("maxSeries must be positive: %d", config.MaxSeries)
+	}
+
+	return config, nil
+}
+
+// applyAliases applies the aliases to the devices. Aliases are applied before
+// devices are selected, rules match the name of the alias.
+func (dc *DevicesConfig) applyAliases(devices []*device) {
+	for _, dev := range devices {
+		alias, ok := dc.Aliases[strings.ToUpper(dev.Key)]
+		if !ok {
+			continue
+		}
+
+		dev.alias = alias
+
+		if alias.Name != "" {
+			dev.Name = alias.Name
+		}
+	}
+}
+
+// matches returns true if the rule matches the device.
+func (r *DeviceRule) matches(dev *device) bool {
+	switch {
+	case r.MAC != "" && !strings.EqualFold(r.MAC, dev.Key):
+		return false
+	case r.name != nil && !r.name.MatchString(dev.Name):
+		return false
+	case r.Type != "" && r.Type != dev.DeviceType:
+		return false
+	case r.Interface != "" && r.Interface != dev.Layer2Interface && r.Interface != dev.SSID:
+		return false
+	case r.RandomMAC != nil && *r.RandomMAC != oui.IsLocallyAdministered(dev.Key):
+		return false
+	default:
+		return true
+	}
+}
+
+// isSelected returns true if the device is included and not excluded.
+func (dc *DevicesConfig) isSelected(dev *device) bool {
+	matches := func(r *DeviceRule) bool { return r.matches(dev) }
+
+	if len(dc.Include) > 0 && !slices.ContainsFunc(dc.Include, matches) {
+		return false
+	}
+
+	return !slices.ContainsFunc(dc.Exclude, matches)
+}
+
+// selectDevices returns the devices to export individually, the other devices
+// and the number of devices dropped because MaxSeries was reached.
+func (dc *DevicesConfig) selectDevices(devices []*device) (selected, others []*device, dropped int) {
+	for _, dev := range devices {
+		if dc.isSelected(dev) {
+			selected = append(selected, dev)
+		} else {
+			others = append(others, dev)
+		}
+	}
+
+	if dc.MaxSeries == 0 || len(selected) <= dc.MaxSeries {
+		return selected, others, 0
+	}
+
+	// Prefer active devices, then sort by MAC for stable series.
+	slices.SortStableFunc(selected, func(a, b *device) int {
+		if a.Active != b.Active {
+			if a.Active {
+				return -1
+			}
+			return 1
+		}
+
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	dropped = len(selected) - dc.MaxSeries
+
+	return selected[:dc.MaxSeries], append(others, selected[dc.MaxSeries:]...), dropped
+}
@@ -0,0 +1,163 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestDeviceRuleMatches(t *testing.T) {
+	yes, no := true, false
+
+	dev := &device{
+		Key:             "00:1A:2B:00:00:01",
+		Name:            "Pixel-7",
+		DeviceType:      "Mobile",
+		Layer2Interface: "wl0",
+		SSID:            "Home",
+	}
+
+	// 02:... is a locally administered (randomized) MAC address.
+	random := &device{Key: "02:1A:2B:00:00:01", Name: "iPhone"}
+
+	for name, tc := range map[string]struct {
+		rule *DeviceRule
+		dev  *device
+		want bool
+	}{
+		"empty rule":           {&DeviceRule{}, dev, true},
+		"mac":                  {&DeviceRule{MAC: "00:1a:2b:00:00:01"}, dev, true},
+		"other mac":            {&DeviceRule{MAC: "00:1A:2B:00:00:02"}, dev, false},
+		"name":                 {&DeviceRule{name: regexp.MustCompile("^Pixel-")}, dev, true},
+		"other name":           {&DeviceRule{name: regexp.MustCompile("^iPhone")}, dev, false},
+		"type":                 {&DeviceRule{Type: "Mobile"}, dev, true},
+		"other type":           {&DeviceRule{Type: "Computer"}, dev, false},
+		"interface":            {&DeviceRule{Interface: "wl0"}, dev, true},
+		"ssid":                 {&DeviceRule{Interface: "Home"}, dev, true},
+		"other interface":      {&DeviceRule{Interface: "eth1"}, dev, false},
+		"random mac":           {&DeviceRule{RandomMAC: &yes}, random, true},
+		"not random mac":       {&DeviceRule{RandomMAC: &no}, random, false},
+		"universal mac":        {&DeviceRule{RandomMAC: &no}, dev, true},
+		"all fields":           {&DeviceRule{MAC: "00:1A:2B:00:00:01", Type: "Mobile", Interface: "Home", RandomMAC: &no}, dev, true},
+		"one field mismatches": {&DeviceRule{MAC: "00:1A:2B:00:00:01", Type: "Computer"}, dev, false},
+	} {
+		if got := tc.rule.matches(tc.dev); got != tc.want {
+			t.Errorf("%s: matches() = %t, want %t", name, got, tc.want)
+		}
+	}
+}
+
+func TestSelectDevices(t *testing.T) {
+	devices := []*device{
+		{Key: "AA:BB:CC:00:00:04", DeviceType: "Computer", Active: true},
+		{Key: "AA:BB:CC:00:00:03", DeviceType: "Mobile", Active: false},
+		{Key: "AA:BB:CC:00:00:02", DeviceType: "Mobile", Active: true},
+		{Key: "AA:BB:CC:00:00:01", DeviceType: "Printer", Active: true},
+	}
+
+	keys := func(devices []*device) (keys []string) {
+		for _, dev := range devices {
+			keys = append(keys, dev.Key)
+		}
+
+		slices.Sort(keys)
+
+		return
+	}
+
+	for name, tc := range map[string]struct {
+		config   *DevicesConfig
+		selected []string
+		dropped  int
+	}{
+		"all": {
+			config:   &DevicesConfig{},
+			selected: []string{"AA:BB:CC:00:00:01", "AA:BB:CC:00:00:02", "AA:BB:CC:00:00:03", "AA:BB:CC:00:00:04"},
+		},
+		"include": {
+			config:   &DevicesConfig{Include: []*DeviceRule{{Type: "Mobile"}, {Type: "Printer"}}},
+			selected: []string{"AA:BB:CC:00:00:01", "AA:BB:CC:00:00:02", "AA:BB:CC:00:00:03"},
+		},
+		"exclude": {
+			config:   &DevicesConfig{Exclude: []*DeviceRule{{Type: "Mobile"}}},
+			selected: []string{"AA:BB:CC:00:00:01", "AA:BB:CC:00:00:04"},
+		},
+		"include and exclude": {
+			config: &DevicesConfig{
+				Include: []*DeviceRule{{Type: "Mobile"}},
+				Exclude: []*DeviceRule{{MAC: "AA:BB:CC:00:00:03"}},
+			},
+			selected: []string{"AA:BB:CC:00:00:02"},
+		},
+		"max series": {
+			// Active devices first, then by MAC.
+			config:   &DevicesConfig{MaxSeries: 2},
+			selected: []string{"AA:BB:CC:00:00:01", "AA:BB:CC:00:00:02"},
+			dropped:  2,
+		},
+		"max series after exclusion": {
+			config:   &DevicesConfig{MaxSeries: 3, Exclude: []*DeviceRule{{Type: "Printer"}}},
+			selected: []string{"AA:BB:CC:00:00:02", "AA:BB:CC:00:00:03", "AA:BB:CC:00:00:04"},
+		},
+	} {
+		selected, others, dropped := tc.config.selectDevices(slices.Clone(devices))
+
+		if got := keys(selected); !slices.Equal(got, tc.selected) {
+			t.Errorf("%s: selected = %v, want %v", name, got, tc.selected)
+		}
+
+		if len(selected)+len(others) != len(devices) {
+			t.Errorf("%s: %d selected and %d other devices, want %d devices", name, len(selected), len(others), len(devices))
+		}
+
+		if dropped != tc.dropped {
+			t.Errorf("%s: dropped = %d, want %d", name, dropped, tc.dropped)
+		}
+	}
+}
+
+func TestLoadDevicesConfig(t *testing.T) {
+	write := func(content string) string {
+		path := filepath.Join(t.TempDir(), "devices.json")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		return path
+	}
+
+	config, err := LoadDevicesConfig(write(`{
+		"include": [{"name": "^Pixel-"}],
+		"exclude": [{"randomMac": true}],
+		"maxSeries": 10,
+		"aliases": {"aa:bb:cc:00:00:01": {"name": "Desktop", "owner": "Alice"}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !config.Include[0].matches(&device{Key: "AA:BB:CC:00:00:02", Name: "Pixel-7"}) {
+		t.Error("the name regular expression was not compiled")
+	}
+
+	// Aliases are indexed by uppercase MAC addresses, as returned by the
+	// Livebox.
+	devices := []*device{{Key: "AA:BB:CC:00:00:01", Name: "PC-1234"}}
+	config.applyAliases(devices)
+
+	if devices[0].Name != "Desktop" || devices[0].alias == nil || devices[0].alias.Owner != "Alice" {
+		t.Errorf("alias was not applied: %+v", devices[0])
+	}
+
+	for name, content := range map[string]string{
+		"invalid regular expression": `{"include": [{"name": "("}]}`,
+		"negative max series":        `{"maxSeries": -1}`,
+		"invalid JSON":               `{`,
+	} {
+		if _, err := LoadDevicesConfig(write(content)); err == nil {
+			t.Errorf("%s: LoadDevicesConfig() succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package collector
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Tomy2e/livebox-exporter/pkg/oui"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// device is a device returned by the Livebox Devices API.
+type device struct {
+	Key                    string `json:"Key"`
+	Name                   string `json:"Name"`
+	DeviceType             string `json:"DeviceType"`
+	Active                 bool   `json:"Active"`
+	Tags                   string `json:"Tags"`
+	IPAddress              string `json:"IPAddress"`
+	Layer2Interface        string `json:"Layer2Interface"`
+	SSID                   string `json:"SSID"`
+	OperatingFrequencyBand string `json:"OperatingFrequencyBand"`
+	Manufacturer           string `json:"Manufacturer"`
+	FirstSeen              string `json:"FirstSeen"`
+	LastConnection         string `json:"LastConnection"`
+	IPv6Address            []struct {
+		Address string `json:"Address"`
+	} `json:"IPv6Address"`
+
+	// alias of the device, can be nil.
+	alias *DeviceAlias
+}
+
+// Layer returns how the device is connected to the Livebox: "wifi",
+// "ethernet" or an empty string if unknown.
+func (d *device) Layer() string {
+	tags := strings.Fields(d.Tags)
+
+	switch {
+	case d.SSID != "" || slices.Contains(tags, "wifi"):
+		return "wifi"
+	case slices.Contains(tags, "eth"):
+		return "ethernet"
+	default:
+		return ""
+	}
+}
+
+// vendor returns the vendor of a device. The manufacturer reported by the
+// Livebox is preferred, the OUI database is used as a fallback.
+func (d *Devices) vendor(dev *device) string {
+	if dev.Manufacturer != "" {
+		return dev.Manufacturer
+	}
+
+	return d.ouiDatabase.Lookup(dev.Key)
+}
+
+// parseTimestamp parses a Livebox timestamp and returns it as seconds since
+// epoch. It returns false if the timestamp is invalid or unset.
+func parseTimestamp(value string) (float64, bool) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil || t.Year() <= 1970 {
+		return 0, false
+	}
+
+	return float64(t.Unix()), true
+}
+
+// collectInfo sends the inventory metrics of a device.
+func (d *Devices) collectInfo(c chan<- prometheus.Metric, dev *device) {
+	alias := dev.alias
+	if alias == nil {
+		alias = &DeviceAlias{}
+	}
+
+	c <- prometheus.MustNewConstMetric(
+		d.deviceInfo,
+		prometheus.GaugeValue,
+		1,
+		dev.Name,
+		dev.DeviceType,
+		dev.Key,
+		dev.IPAddress,
+		dev.Layer2Interface,
+		dev.SSID,
+		dev.Layer(),
+		dev.OperatingFrequencyBand,
+		d.vendor(dev),
+		strconv.FormatBool(oui.IsLocallyAdministered(dev.Key)),
+		alias.Owner,
+		alias.Room,
+		alias.Group,
+	)
+
+	c <- prometheus.MustNewConstMetric(
+		d.deviceIPv6Addresses,
+		prometheus.GaugeValue,
+		float64(len(dev.IPv6Address)),
+		dev.Name,
+		dev.DeviceType,
+		dev.Key,
+	)
+
+	if ts, ok := parseTimestamp(dev.FirstSeen); ok {
+		c <- prometheus.MustNewConstMetric(
+			d.deviceFirstSeen,
+			prometheus.GaugeValue,
+			ts,
+			dev.Name,
+			dev.DeviceType,
+			dev.Key,
+		)
+	}
+
+	if ts, ok := parseTimestamp(dev.LastConnection); ok {
+		c <- prometheus.MustNewConstMetric(
+			d.deviceLastConnection,
+			prometheus.GaugeValue,
+			ts,
+			dev.Name,
+			dev.DeviceType,
+			dev.Key,
+		)
+	}
+}
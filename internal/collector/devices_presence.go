@@ -0,0 +1,239 @@
+package collector
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Reasons of the Devices.Device events handled by the presence tracker.
+const (
+	eventReasonChanged       = "changed"
+	eventReasonDeviceAdded   = "device_added"
+	eventReasonDeviceUpdated = "device_updated"
+)
+
+// devicePresence is the presence state of a device.
+type devicePresence struct {
+	active       bool
+	connections  uint64
+	lastSeen     time.Time
+	sessionStart time.Time
+	sessionEnd   time.Time
+	// updated is the last time the device was listed by the Livebox or
+	// reported by an event.
+	updated time.Time
+}
+
+// sessionDuration returns the duration of the current session if the device
+// is active, or the duration of the last session otherwise.
+func (dp *devicePresence) sessionDuration(now time.Time) time.Duration {
+	switch {
+	case dp.sessionStart.IsZero():
+		return 0
+	case dp.active:
+		return now.Sub(dp.sessionStart)
+	case dp.sessionEnd.After(dp.sessionStart):
+		return dp.sessionEnd.Sub(dp.sessionStart)
+	default:
+		return 0
+	}
+}
+
+// presence tracks when devices connect to and disconnect from the Livebox,
+// using Devices.Device events and the devices returned on each collect.
+type presence struct {
+	mu      sync.Mutex
+	devices map[string]*devicePresence
+	// synced is true once the devices were listed a first time. Devices seen
+	// before that are not considered as new devices.
+	synced     bool
+	newDevices prometheus.Counter
+}
+
+func newPresence() *presence {
+	return &presence{
+		devices: make(map[string]*devicePresence),
+		newDevices: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "livebox_devices_new_total",
+			Help: "Number of devices seen for the first time.",
+		}),
+	}
+}
+
+// device returns the presence of a device, creating it if needed.
+// Must be called with the lock held.
+func (p *presence) device(mac string) *devicePresence {
+	dp, ok := p.devices[mac]
+	if !ok {
+		dp = &devicePresence{updated: time.Now()}
+		p.devices[mac] = dp
+
+		if p.synced {
+			p.newDevices.Inc()
+		}
+	}
+
+	return dp
+}
+
+// setActive updates the presence of a device. Must be called with the lock held.
+func (p *presence) setActive(mac string, active bool, now time.Time) {
+	dp := p.device(mac)
+
+	switch {
+	case active && !dp.active:
+		dp.connections++
+		dp.sessionStart = now
+	case !active && dp.active:
+		dp.sessionEnd = now
+	}
+
+	if active || dp.active {
+		dp.lastSeen = now
+	}
+
+	dp.active = active
+}
+
+// handleEvent updates the presence of a device from a Devices.Device event.
+func (p *presence) handleEvent(handler, reason string, attributes any) {
+	mac := strings.TrimPrefix(handler, "Devices.Device.")
+	if !strings.Contains(mac, ":") {
+		return
+	}
+
+	var attrs struct {
+		Active *bool
+	}
+
+	if err := mapstructure.Decode(attributes, &attrs); err != nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch reason {
+	case eventReasonDeviceAdded, eventReasonDeviceUpdated:
+		p.device(mac)
+	case eventReasonChanged:
+		if attrs.Active == nil {
+			return
+		}
+	default:
+		return
+	}
+
+	p.device(mac).updated = time.Now()
+
+	if attrs.Active != nil {
+		p.setActive(mac, *attrs.Active, time.Now())
+	}
+}
+
+// seen updates the last time a device was seen.
+func (p *presence) seen(mac string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if dp, ok := p.devices[mac]; ok {
+		dp.lastSeen = time.Now()
+		dp.updated = dp.lastSeen
+	}
+}
+
+// sync updates the presence of devices from the list of devices returned by
+// the Livebox, to catch up with missed events. Devices that are no longer
+// listed by the Livebox are forgotten when they were not updated within ttl.
+func (p *presence) sync(devices []*device, ttl time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+
+	for _, dev := range devices {
+		dp, ok := p.devices[dev.Key]
+
+		// Do not count connections of devices already active on the first
+		// sync, the start of their session is unknown.
+		if !ok && !p.synced {
+			dp = p.device(dev.Key)
+			dp.active = dev.Active
+
+			if dev.Active {
+				dp.lastSeen = now
+			}
+
+			continue
+		}
+
+		p.setActive(dev.Key, dev.Active, now)
+		p.devices[dev.Key].updated = now
+	}
+
+	deadline := now.Add(-ttl)
+
+	for mac, dp := range p.devices {
+		if dp.updated.Before(deadline) {
+			delete(p.devices, mac)
+		}
+	}
+
+	p.synced = true
+}
+
+// get returns the presence of a device.
+func (p *presence) get(mac string) (devicePresence, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	dp, ok := p.devices[mac]
+	if !ok {
+		return devicePresence{}, false
+	}
+
+	return *dp, true
+}
+
+// collectPresence sends the presence metrics of a device.
+func (d *Devices) collectPresence(c chan<- prometheus.Metric, dev *device) {
+	dp, ok := d.presence.get(dev.Key)
+	if !ok {
+		return
+	}
+
+	c <- prometheus.MustNewConstMetric(
+		d.deviceConnections,
+		prometheus.CounterValue,
+		float64(dp.connections),
+		dev.Name,
+		dev.DeviceType,
+		dev.Key,
+	)
+
+	if !dp.lastSeen.IsZero() {
+		c <- prometheus.MustNewConstMetric(
+			d.deviceLastSeen,
+			prometheus.GaugeValue,
+			float64(dp.lastSeen.Unix()),
+			dev.Name,
+			dev.DeviceType,
+			dev.Key,
+		)
+	}
+
+	if !dp.sessionStart.IsZero() {
+		c <- prometheus.MustNewConstMetric(
+			d.deviceSessionDuration,
+			prometheus.GaugeValue,
+			dp.sessionDuration(time.Now()).Seconds(),
+			dev.Name,
+			dev.DeviceType,
+			dev.Key,
+		)
+	}
+}
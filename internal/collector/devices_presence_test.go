@@ -0,0 +1,164 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	exporterLivebox "github.com/Tomy2e/livebox-exporter/pkg/livebox"
+)
+
+func TestPresenceExpire(t *testing.T) {
+	const listed, gone = "02:00:00:00:00:01", "02:00:00:00:00:02"
+
+	p := newPresence()
+	p.sync([]*device{{Key: listed}, {Key: gone}}, time.Hour)
+
+	// The device is no longer listed by the Livebox, it is forgotten once it
+	// was not updated within the TTL.
+	p.sync([]*device{{Key: listed}}, time.Hour)
+
+	if _, ok := p.get(gone); !ok {
+		t.Fatal("presence of the unlisted device was forgotten before the TTL")
+	}
+
+	p.devices[gone].updated = time.Now().Add(-2 * time.Hour)
+	p.devices[listed].updated = time.Now().Add(-2 * time.Hour)
+	p.sync([]*device{{Key: listed}}, time.Hour)
+
+	if _, ok := p.get(gone); ok {
+		t.Error("presence of the unlisted device was not forgotten")
+	}
+
+	if _, ok := p.get(listed); !ok {
+		t.Error("presence of the listed device was forgotten")
+	}
+}
+
+// waitFor collects the metrics until check returns true, as events are
+// handled asynchronously.
+func waitFor(t *testing.T, d *Devices, check func(metrics map[string][]testSample) bool) map[string][]testSample {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for {
+		metrics := collect(t, d)
+		if check(metrics) {
+			return metrics
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected metrics: %v", metrics)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// value returns the value of the metric of a device, or -1 if it is missing.
+func value(metrics map[string][]testSample, name, mac string) float64 {
+	s, ok := find(metrics[name], mac)
+	if !ok {
+		return -1
+	}
+
+	return s.value
+}
+
+func TestPresenceEvents(t *testing.T) {
+	const (
+		known   = "AA:BB:CC:00:00:01"
+		unknown = "AA:BB:CC:00:00:02"
+	)
+
+	api := newFakeAPI(&device{Key: known, Name: "Laptop", Active: true})
+	d := newTestDevices(t, api)
+
+	send := func(mac, reason string, attributes map[string]any) {
+		t.Helper()
+
+		select {
+		case api.events <- &exporterLivebox.EventMessage{Event: &exporterLivebox.Event{
+			Handler:    "Devices.Device." + mac,
+			Reason:     reason,
+			Attributes: attributes,
+		}}:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the event was not received")
+		}
+	}
+
+	// The first sync does not count the connection of devices already
+	// active, the start of their session is unknown.
+	metrics := collect(t, d)
+
+	if got := value(metrics, "livebox_device_connections_total", known); got != 0 {
+		t.Errorf("connections of the known device = %g, want 0", got)
+	}
+
+	if got := value(metrics, "livebox_device_session_duration_seconds", known); got != -1 {
+		t.Errorf("session duration of the known device = %g, want none", got)
+	}
+
+	if got := metrics["livebox_devices_new_total"]; len(got) != 1 || got[0].value != 0 {
+		t.Errorf("livebox_devices_new_total = %+v, want 0", got)
+	}
+
+	// The known device disconnects and reconnects.
+	api.setDevices(&device{Key: known, Name: "Laptop", Active: false})
+	send(known, eventReasonChanged, map[string]any{"Active": false})
+
+	api.setDevices(&device{Key: known, Name: "Laptop", Active: true})
+	send(known, eventReasonChanged, map[string]any{"Active": true})
+
+	waitFor(t, d, func(metrics map[string][]testSample) bool {
+		return value(metrics, "livebox_device_connections_total", known) == 1 &&
+			value(metrics, "livebox_device_session_duration_seconds", known) >= 0 &&
+			value(metrics, "livebox_device_last_seen_timestamp_seconds", known) > 0
+	})
+
+	// A new device is added, then connects.
+	send(unknown, eventReasonDeviceAdded, map[string]any{"Active": false})
+	send(unknown, eventReasonDeviceUpdated, map[string]any{"Active": true})
+
+	api.setDevices(
+		&device{Key: known, Name: "Laptop", Active: true},
+		&device{Key: unknown, Name: "Phone", Active: true},
+	)
+
+	metrics = waitFor(t, d, func(metrics map[string][]testSample) bool {
+		return value(metrics, "livebox_device_connections_total", unknown) == 1
+	})
+
+	if got := metrics["livebox_devices_new_total"]; len(got) != 1 || got[0].value != 1 {
+		t.Errorf("livebox_devices_new_total = %+v, want 1", got)
+	}
+
+	// Events without the Active attribute do not change the presence.
+	send(unknown, eventReasonChanged, map[string]any{"Name": "Phone"})
+
+	// The new device disconnects, the duration of its last session is kept.
+	time.Sleep(50 * time.Millisecond)
+
+	api.setDevices(
+		&device{Key: known, Name: "Laptop", Active: true},
+		&device{Key: unknown, Name: "Phone", Active: false},
+	)
+	send(unknown, eventReasonChanged, map[string]any{"Active": false})
+
+	metrics = waitFor(t, d, func(metrics map[string][]testSample) bool {
+		return value(metrics, "livebox_device_session_duration_seconds", unknown) >= 0.05
+	})
+
+	duration := value(metrics, "livebox_device_session_duration_seconds", unknown)
+
+	time.Sleep(50 * time.Millisecond)
+
+	if got := value(collect(t, d), "livebox_device_session_duration_seconds", unknown); got != duration {
+		t.Errorf("session duration of the disconnected device = %g, want %g", got, duration)
+	}
+
+	if got := value(metrics, "livebox_device_connections_total", unknown); got != 1 {
+		t.Errorf("connections of the new device = %g, want 1", got)
+	}
+}
@@ -0,0 +1,213 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Tomy2e/livebox-api-client/api/request"
+	exporterLivebox "github.com/Tomy2e/livebox-exporter/pkg/livebox"
+	"github.com/Tomy2e/livebox-exporter/pkg/oui"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fakeAPI is a Livebox API that lists devices and sends the events of the
+// test.
+type fakeAPI struct {
+	mu      sync.Mutex
+	devices []*device
+	events  chan *exporterLivebox.EventMessage
+}
+
+func newFakeAPI(devices ...*device) *fakeAPI {
+	return &fakeAPI{devices: devices, events: make(chan *exporterLivebox.EventMessage)}
+}
+
+// setDevices sets the devices listed by the Livebox.
+func (fa *fakeAPI) setDevices(devices ...*device) {
+	fa.mu.Lock()
+	defer fa.mu.Unlock()
+
+	fa.devices = devices
+}
+
+func (fa *fakeAPI) Request(_ context.Context, req *request.Request, out any) error {
+	if req.Service != "Devices" || req.Method != "get" {
+		return fmt.Errorf("unsupported request: %s.%s", req.Service, req.Method)
+	}
+
+	fa.mu.Lock()
+	b, err := json.Marshal(map[string]any{"status": fa.devices})
+	fa.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, out)
+}
+
+func (fa *fakeAPI) Events(ctx context.Context, _ []string) <-chan *exporterLivebox.EventMessage {
+	events := make(chan *exporterLivebox.EventMessage)
+
+	go func() {
+		defer close(events)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-fa.events:
+				select {
+				case events <- msg:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events
+}
+
+func (fa *fakeAPI) Session() exporterLivebox.SessionInfo {
+	return exporterLivebox.SessionInfo{}
+}
+
+// newTestDevices returns a Devices collector whose goroutines stop at the end
+// of the test.
+func newTestDevices(t *testing.T, api exporterLivebox.API, opts ...DevicesOption) *Devices {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	return NewDevices(api, nil, append([]DevicesOption{
+		WithContext(ctx),
+		WithOUIDatabase(&oui.Database{}),
+	}, opts...)...)
+}
+
+// testSample is a collected metric.
+type testSample struct {
+	labels map[string]string
+	value  float64
+	// timestampMs is the explicit timestamp of the sample, or 0.
+	timestampMs int64
+}
+
+// collect returns the metrics of the collector by name.
+func collect(t *testing.T, c prometheus.Collector) map[string][]testSample {
+	t.Helper()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c)
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples := make(map[string][]testSample)
+
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			s := testSample{labels: make(map[string]string)}
+
+			for _, label := range m.GetLabel() {
+				s.labels[label.GetName()] = label.GetValue()
+			}
+
+			s.value = m.GetGauge().GetValue() + m.GetCounter().GetValue()
+			s.timestampMs = m.GetTimestampMs()
+			samples[family.GetName()] = append(samples[family.GetName()], s)
+		}
+	}
+
+	return samples
+}
+
+// find returns the sample of a device, or false if there is none.
+func find(samples []testSample, mac string) (testSample, bool) {
+	for _, s := range samples {
+		if s.labels["mac"] == mac {
+			return s, true
+		}
+	}
+
+	return testSample{}, false
+}
+
+func TestDevicesAliases(t *testing.T) {
+	api := newFakeAPI(
+		&device{Key: "AA:BB:CC:00:00:01", Name: "PC-1234", DeviceType: "Computer", Active: true},
+		&device{Key: "AA:BB:CC:00:00:02", Name: "Phone", DeviceType: "Mobile", Active: true},
+	)
+
+	d := newTestDevices(t, api, WithConfig(&DevicesConfig{
+		Aliases: map[string]*DeviceAlias{
+			"AA:BB:CC:00:00:01": {Name: "Desktop", Owner: "Alice", Room: "Office", Group: "work"},
+		},
+	}))
+
+	metrics := collect(t, d)
+
+	for mac, want := range map[string]map[string]string{
+		"AA:BB:CC:00:00:01": {"name": "Desktop", "owner": "Alice", "room": "Office", "group": "work"},
+		"AA:BB:CC:00:00:02": {"name": "Phone", "owner": "", "room": "", "group": ""},
+	} {
+		info, ok := find(metrics["livebox_device_info"], mac)
+		if !ok {
+			t.Fatalf("livebox_device_info of %s is missing", mac)
+		}
+
+		for label, value := range want {
+			if info.labels[label] != value {
+				t.Errorf("livebox_device_info{mac=%q} %s = %q, want %q", mac, label, info.labels[label], value)
+			}
+		}
+	}
+
+	// The alias name is used by all the metrics of the device.
+	if active, ok := find(metrics["livebox_device_active"], "AA:BB:CC:00:00:01"); !ok || active.labels["name"] != "Desktop" {
+		t.Errorf("livebox_device_active of the aliased device = %+v", active)
+	}
+}
+
+func TestDevicesMaxSeries(t *testing.T) {
+	api := newFakeAPI(
+		&device{Key: "AA:BB:CC:00:00:01", Name: "Inactive", Active: false},
+		&device{Key: "AA:BB:CC:00:00:02", Name: "Active 1", Active: true},
+		&device{Key: "AA:BB:CC:00:00:03", Name: "Active 2", Active: true},
+	)
+
+	d := newTestDevices(t, api, WithConfig(&DevicesConfig{
+		MaxSeries:       1,
+		AggregateOthers: true,
+	}))
+
+	// The dropped devices are not counted again on each collect.
+	for range 2 {
+		metrics := collect(t, d)
+
+		if got := metrics["livebox_device_series_dropped"]; len(got) != 1 || got[0].value != 2 {
+			t.Errorf("livebox_device_series_dropped = %+v, want 2", got)
+		}
+
+		if got := metrics["livebox_devices_other_active"]; len(got) != 1 || got[0].value != 1 {
+			t.Errorf("livebox_devices_other_active = %+v, want 1", got)
+		}
+
+		// Active devices are preferred, then devices are sorted by MAC.
+		active := metrics["livebox_device_active"]
+		if len(active) != 1 || active[0].labels["mac"] != "AA:BB:CC:00:00:02" {
+			t.Errorf("livebox_device_active = %+v, want only AA:BB:CC:00:00:02", active)
+		}
+
+		if _, ok := find(metrics["livebox_device_rx_mbits"], otherDevice); !ok {
+			t.Error("the rates of the other devices are missing")
+		}
+	}
+}
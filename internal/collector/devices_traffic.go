@@ -0,0 +1,294 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Tomy2e/livebox-exporter/pkg/bitrate"
+)
+
+// deviceTrafficSaveInterval is the delay between each save of the device
+// traffic file.
+const deviceTrafficSaveInterval = time.Minute
+
+// stationStatsExpiry is the delay after which a device that no longer appears
+// in station stats, for example because it moved to Ethernet, is counted
+// through events again.
+const stationStatsExpiry = 2 * time.Minute
+
+// deviceTraffic accumulates the traffic of each device in the exporter, so that
+// totals survive Livebox counter resets.
+type deviceTraffic struct {
+	mu sync.Mutex
+	// totals contains the accumulated Tx and Rx bytes of each device.
+	totals map[string]*bitrate.Counters
+	// stationStats contains the last time station stats were received for
+	// each device. Station stats are more accurate, events are ignored for
+	// these devices until the station stats expire.
+	stationStats map[string]time.Time
+	// updated contains the last time the traffic of each device was reported.
+	updated  map[string]time.Time
+	events   *bitrate.Accumulator
+	stations *bitrate.Accumulator
+	// others contains the accumulated traffic of the devices aggregated into
+	// the "other" device, othersLast contains the totals of these devices at
+	// the last aggregation.
+	others     bitrate.Counters
+	othersLast map[string]bitrate.Counters
+}
+
+// deviceTrafficFile is the content of the device traffic file.
+type deviceTrafficFile struct {
+	Totals       map[string]*bitrate.Counters `json:"totals"`
+	StationStats map[string]time.Time         `json:"stationStatsTimes"`
+	Events       map[string]bitrate.State     `json:"events"`
+	Stations     map[string]bitrate.State     `json:"stations"`
+	Others       bitrate.Counters             `json:"others"`
+}
+
+func newDeviceTraffic() *deviceTraffic {
+	return &deviceTraffic{
+		totals:       make(map[string]*bitrate.Counters),
+		stationStats: make(map[string]time.Time),
+		updated:      make(map[string]time.Time),
+		events:       bitrate.NewAccumulator(),
+		stations:     bitrate.NewAccumulator(),
+		othersLast:   make(map[string]bitrate.Counters),
+	}
+}
+
+// addEvents adds the traffic of a device reported by events.
+func (dt *deviceTraffic) addEvents(mac string, current *bitrate.Counters) {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	increases := dt.events.Increases(mac, current)
+	now := time.Now()
+	dt.updated[mac] = now
+
+	if !dt.hasStationStats(mac, now) {
+		dt.add(mac, increases)
+	}
+}
+
+// hasStationStats returns true if station stats were recently received for a
+// device, expired station stats are removed.
+func (dt *deviceTraffic) hasStationStats(mac string, now time.Time) bool {
+	last, ok := dt.stationStats[mac]
+	if ok && now.Sub(last) > stationStatsExpiry {
+		delete(dt.stationStats, mac)
+		return false
+	}
+
+	return ok
+}
+
+// addStationStats adds the traffic of a device reported by station stats.
+func (dt *deviceTraffic) addStationStats(mac string, current *bitrate.Counters) {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	increases := dt.stations.Increases(mac, current)
+	now := time.Now()
+	dt.updated[mac] = now
+
+	// Do not count the increase when switching from events to station stats,
+	// it was already counted through events.
+	switching := !dt.hasStationStats(mac, now)
+	dt.stationStats[mac] = now
+
+	if _, ok := dt.totals[mac]; switching && ok {
+		return
+	}
+
+	dt.add(mac, increases)
+}
+
+func (dt *deviceTraffic) add(mac string, increases *bitrate.Counters) {
+	total, ok := dt.totals[mac]
+	if !ok {
+		total = &bitrate.Counters{}
+		dt.totals[mac] = total
+	}
+
+	total.Tx += increases.Tx
+	total.Rx += increases.Rx
+}
+
+// aggregate adds the traffic of the devices aggregated into the "other"
+// device since the last aggregation, and returns the accumulated traffic of
+// the "other" device. The traffic of a device is only added while it is
+// aggregated, so that the accumulated traffic never decreases when devices
+// are exported individually or disappear.
+func (dt *deviceTraffic) aggregate(macs []string) bitrate.Counters {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	last := make(map[string]bitrate.Counters, len(macs))
+
+	for _, mac := range macs {
+		total, ok := dt.totals[mac]
+		if !ok {
+			continue
+		}
+
+		if previous, ok := dt.othersLast[mac]; ok {
+			if total.Tx >= previous.Tx {
+				dt.others.Tx += total.Tx - previous.Tx
+			}
+
+			if total.Rx >= previous.Rx {
+				dt.others.Rx += total.Rx - previous.Rx
+			}
+		}
+
+		last[mac] = *total
+	}
+
+	dt.othersLast = last
+
+	return dt.others
+}
+
+// expire forgets the devices whose traffic was not reported within ttl, so
+// that the state of devices that are gone does not grow forever. The traffic
+// of a forgotten device that comes back starts again from its Livebox
+// counters.
+func (dt *deviceTraffic) expire(ttl time.Duration) {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	deadline := time.Now().Add(-ttl)
+
+	for mac, updated := range dt.updated {
+		if updated.After(deadline) {
+			continue
+		}
+
+		delete(dt.updated, mac)
+		delete(dt.totals, mac)
+		delete(dt.stationStats, mac)
+		delete(dt.othersLast, mac)
+		dt.events.Delete(mac)
+		dt.stations.Delete(mac)
+	}
+}
+
+// get returns the accumulated traffic of a device.
+func (dt *deviceTraffic) get(mac string) (bitrate.Counters, bool) {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	total, ok := dt.totals[mac]
+	if !ok {
+		return bitrate.Counters{}, false
+	}
+
+	return *total, true
+}
+
+// load loads the device traffic from a file. A missing file is not an error.
+func (dt *deviceTraffic) load(path string) error {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read device traffic file: %w", err)
+	}
+
+	var file deviceTrafficFile
+	if err := json.Unmarshal(b, &file); err != nil {
+		return fmt.Errorf("failed to decode device traffic file: %w", err)
+	}
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	// The devices are considered as updated when loaded, so that they do not
+	// expire if the exporter was stopped for a long time.
+	now := time.Now()
+
+	for mac, total := range file.Totals {
+		dt.totals[mac] = total
+		dt.updated[mac] = now
+	}
+
+	for mac, last := range file.StationStats {
+		dt.stationStats[mac] = last
+	}
+
+	dt.others = file.Others
+	dt.events.Restore(file.Events)
+	dt.stations.Restore(file.Stations)
+
+	return nil
+}
+
+// save atomically saves the device traffic to a file.
+func (dt *deviceTraffic) save(path string) error {
+	dt.mu.Lock()
+	b, err := json.Marshal(&deviceTrafficFile{
+		Totals:       dt.totals,
+		StationStats: dt.stationStats,
+		Events:       dt.events.States(),
+		Stations:     dt.stations.States(),
+		Others:       dt.others,
+	})
+	dt.mu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("failed to encode device traffic: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create device traffic file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write device traffic file: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write device traffic file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to rename device traffic file: %w", err)
+	}
+
+	return nil
+}
+
+// startSaving periodically saves the device traffic to a file, until the
+// context is canceled. The traffic is saved one last time when the context is
+// canceled.
+func (dt *deviceTraffic) startSaving(ctx context.Context, path string) {
+	ticker := time.NewTicker(deviceTrafficSaveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+
+		if err := dt.save(path); err != nil {
+			log.Printf("WARN: %s", err)
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Tomy2e/livebox-exporter/pkg/bitrate"
+)
+
+func TestDeviceTrafficSources(t *testing.T) {
+	const mac = "02:00:00:00:00:01"
+
+	dt := newDeviceTraffic()
+
+	dt.addEvents(mac, &bitrate.Counters{Tx: 1000, Rx: 1000})
+	dt.addEvents(mac, &bitrate.Counters{Tx: 1500, Rx: 1500})
+
+	// Switching to station stats does not count the initial value.
+	dt.addStationStats(mac, &bitrate.Counters{Tx: 700, Rx: 700})
+	dt.addStationStats(mac, &bitrate.Counters{Tx: 800, Rx: 800})
+
+	// Events are ignored while station stats are received.
+	dt.addEvents(mac, &bitrate.Counters{Tx: 1600, Rx: 1600})
+
+	if total, _ := dt.get(mac); total.Tx != 1600 {
+		t.Fatalf("total = %d, want 1600", total.Tx)
+	}
+
+	// Events are counted again once station stats expire, for example when
+	// the device moved to Ethernet.
+	dt.stationStats[mac] = time.Now().Add(-stationStatsExpiry - time.Second)
+	dt.addEvents(mac, &bitrate.Counters{Tx: 2600, Rx: 2600})
+
+	if total, _ := dt.get(mac); total.Tx != 2600 {
+		t.Errorf("total = %d, want 2600", total.Tx)
+	}
+}
+
+func TestDeviceTrafficRestoreReset(t *testing.T) {
+	const mac = "02:00:00:00:00:01"
+
+	path := t.TempDir() + "/traffic.json"
+
+	dt := newDeviceTraffic()
+	dt.addEvents(mac, &bitrate.Counters{Tx: 100_000_000, Rx: 100_000_000})
+
+	if err := dt.save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := newDeviceTraffic()
+	if err := restored.load(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// The Livebox was rebooted while the exporter was down.
+	restored.addEvents(mac, &bitrate.Counters{Tx: 5000, Rx: 5000})
+
+	if total, _ := restored.get(mac); total.Tx != 100_005_000 {
+		t.Errorf("total = %d, want 100005000", total.Tx)
+	}
+}
+
+func TestDeviceTrafficAggregate(t *testing.T) {
+	dt := newDeviceTraffic()
+	dt.totals["a"] = &bitrate.Counters{Tx: 1000, Rx: 1000}
+	dt.totals["b"] = &bitrate.Counters{Tx: 5000, Rx: 5000}
+
+	steps := []struct {
+		macs []string
+		a, b uint64
+		want uint64
+	}{
+		// The traffic of devices is counted from their first aggregation.
+		{macs: []string{"a", "b"}, a: 1000, b: 5000, want: 0},
+		{macs: []string{"a", "b"}, a: 1500, b: 6000, want: 1500},
+		// b is exported individually, the aggregate does not decrease.
+		{macs: []string{"a"}, a: 2000, b: 9000, want: 2000},
+		// b is aggregated again, its traffic since then is not counted.
+		{macs: []string{"a", "b"}, a: 2000, b: 9500, want: 2000},
+		{macs: []string{"a", "b"}, a: 2100, b: 9600, want: 2200},
+		// a disappeared.
+		{macs: []string{"b"}, a: 2100, b: 9700, want: 2300},
+	}
+
+	for i, step := range steps {
+		dt.totals["a"].Tx = step.a
+		dt.totals["b"].Tx = step.b
+
+		if got := dt.aggregate(step.macs); got.Tx != step.want {
+			t.Errorf("step %d: aggregate() = %d, want %d", i, got.Tx, step.want)
+		}
+	}
+}
+
+func TestDeviceTrafficExpire(t *testing.T) {
+	const gone, active = "02:00:00:00:00:01", "02:00:00:00:00:02"
+
+	dt := newDeviceTraffic()
+	dt.addEvents(gone, &bitrate.Counters{Tx: 1000, Rx: 1000})
+	dt.addStationStats(active, &bitrate.Counters{Tx: 1000, Rx: 1000})
+	dt.aggregate([]string{gone, active})
+
+	dt.updated[gone] = time.Now().Add(-2 * time.Hour)
+	dt.expire(time.Hour)
+
+	if _, ok := dt.get(gone); ok {
+		t.Error("traffic of the gone device was not forgotten")
+	}
+
+	if _, ok := dt.get(active); !ok {
+		t.Error("traffic of the active device was forgotten")
+	}
+
+	if len(dt.updated) != 1 || len(dt.othersLast) != 1 || len(dt.events.States()) != 0 {
+		t.Errorf("state of the gone device was not forgotten: updated=%d othersLast=%d events=%d",
+			len(dt.updated), len(dt.othersLast), len(dt.events.States()))
+	}
+
+	// A forgotten device that comes back starts again from its counters.
+	dt.addEvents(gone, &bitrate.Counters{Tx: 1500, Rx: 1500})
+
+	if total, _ := dt.get(gone); total.Tx != 1500 {
+		t.Errorf("total = %d, want 1500", total.Tx)
+	}
+}
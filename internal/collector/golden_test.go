@@ -0,0 +1,187 @@
+package collector
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tomy2e/livebox-exporter/internal/fixture"
+	"github.com/Tomy2e/livebox-exporter/internal/liveboxsim"
+	exporterLivebox "github.com/Tomy2e/livebox-exporter/pkg/livebox"
+	"github.com/Tomy2e/livebox-exporter/pkg/oui"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var update = flag.Bool("update", false, "Record the simulator fixtures and update the golden files")
+
+// goldenMetrics are the metrics compared with the golden files, the other
+// metrics depend on the time of the test.
+var goldenMetrics = []string{
+	"livebox_device_active",
+	"livebox_device_info",
+	"livebox_device_ipv6_addresses",
+	"livebox_device_first_seen_timestamp_seconds",
+	"livebox_device_last_connection_timestamp_seconds",
+	"livebox_ont_temperature_celsius",
+	"livebox_ont_downstream_current_rate_bytes",
+	"livebox_ont_upstream_current_rate_bytes",
+}
+
+// simulatorFixtures are the fixtures recorded from the simulator scenarios
+// when the golden files are updated. Fixtures recorded from real Liveboxes
+// with the -record option can be added to testdata, they are never recorded
+// again.
+var simulatorFixtures = map[string]func() *liveboxsim.Scenario{
+	"livebox5-sim": liveboxsim.DefaultScenario,
+}
+
+// TestGolden replays the fixtures of testdata through DiscoverInterfaces and
+// the Devices and ONT collectors, and compares the results with the golden
+// files.
+func TestGolden(t *testing.T) {
+	if *update {
+		for name, newScenario := range simulatorFixtures {
+			// The simulation starts at a fixed time, so that the recorded
+			// timestamps do not change.
+			scenario := newScenario()
+			scenario.Start = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+			recordSimulatorFixture(t, filepath.Join("testdata", name+".jsonl"), scenario)
+		}
+	}
+
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "*.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fixtures) == 0 {
+		t.Fatal("no fixtures found in testdata")
+	}
+
+	for _, path := range fixtures {
+		name := strings.TrimSuffix(filepath.Base(path), ".jsonl")
+
+		t.Run(name, func(t *testing.T) {
+			replayer, err := fixture.NewReplayer(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := replay(t, &http.Client{Transport: replayer}, "http://livebox")
+			goldenPath := filepath.Join("testdata", name+".golden")
+
+			if *update {
+				if err := os.WriteFile(goldenPath, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			want, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != string(want) {
+				t.Errorf("result does not match %s, run go test -update to update it:\n%s", goldenPath, got)
+			}
+		})
+	}
+}
+
+// recordSimulatorFixture records the traffic of replay with the simulator.
+func recordSimulatorFixture(t *testing.T, path string, scenario *liveboxsim.Scenario) {
+	t.Helper()
+
+	server := httptest.NewServer(liveboxsim.New(scenario))
+	defer server.Close()
+
+	recorder, err := fixture.NewRecorder(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recorder.Close()
+
+	replay(t, &http.Client{Transport: recorder}, server.URL)
+}
+
+// noEventsAPI is a Livebox API without events.
+type noEventsAPI struct {
+	exporterLivebox.API
+}
+
+func (noEventsAPI) Events(context.Context, []string) <-chan *exporterLivebox.EventMessage {
+	events := make(chan *exporterLivebox.EventMessage)
+	close(events)
+
+	return events
+}
+
+// replay runs DiscoverInterfaces and collects the Devices and ONT collectors,
+// it returns the discovered interfaces and the collected golden metrics. The
+// embedded OUI database is not used, so that results do not depend on it.
+func replay(t *testing.T, httpClient *http.Client, address string) string {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client, err := exporterLivebox.NewClient(liveboxsim.DefaultScenario().Password, address, httpClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	interfaces, err := exporterLivebox.DiscoverInterfaces(ctx, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+
+	sort.Slice(interfaces, func(i, j int) bool { return interfaces[i].Name < interfaces[j].Name })
+
+	for _, itf := range interfaces {
+		fmt.Fprintf(&b, "interface %s %q\n", itf.Name, itf.Flags)
+	}
+
+	// The Devices collector gets no events and no interfaces, so that it does
+	// not request events or station stats in the background: the recorded
+	// fixtures only contain the requests made by this function, in order.
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		NewDevices(noEventsAPI{client}, nil, WithContext(ctx), WithOUIDatabase(&oui.Database{})),
+		NewONT(client, interfaces),
+	)
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, family := range families {
+		if !slices.Contains(goldenMetrics, family.GetName()) {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			labels := make([]string, 0, len(metric.GetLabel()))
+			for _, label := range metric.GetLabel() {
+				labels = append(labels, fmt.Sprintf("%s=%q", label.GetName(), label.GetValue()))
+			}
+
+			value := metric.GetGauge().GetValue() + metric.GetCounter().GetValue()
+			fmt.Fprintf(&b, "%s{%s} %g\n", family.GetName(), strings.Join(labels, ","), value)
+		}
+	}
+
+	return b.String()
+}
@@ -6,7 +6,6 @@ import (
 	"slices"
 	"time"
 
-	"github.com/Tomy2e/livebox-api-client"
 	"github.com/Tomy2e/livebox-api-client/api/request"
 	exporterLivebox "github.com/Tomy2e/livebox-exporter/pkg/livebox"
 	"github.com/prometheus/client_golang/prometheus"
@@ -16,7 +15,7 @@ const gponInterfaceName = "veip0"
 
 // ONT implements a prometheus Collector that returns ONT specific metrics.
 type ONT struct {
-	client *livebox.Client
+	client exporterLivebox.API
 
 	enabled bool
 
@@ -26,7 +25,7 @@ type ONT struct {
 }
 
 // NewONT returns a new ONT collector using the specified client.
-func NewONT(client *livebox.Client, interfaces []*exporterLivebox.Interface) *ONT {
+func NewONT(client exporterLivebox.API, interfaces []*exporterLivebox.Interface) *ONT {
 	return &ONT{
 		client: client,
 		// Do not enable this collector if veip0 interface is not found.
@@ -0,0 +1,165 @@
+package collector
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Tomy2e/livebox-exporter/pkg/reflect"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+var snapshotLastCollectDesc = prometheus.NewDesc(
+	"livebox_collector_last_collect_timestamp_seconds",
+	"Time when the collector last collected metrics in the background.",
+	[]string{"collector"}, nil,
+)
+
+// SnapshotOptions are the options of a Snapshot.
+type SnapshotOptions struct {
+	// Interval between each collection.
+	Interval time.Duration
+	// StaleIntervals is the number of intervals after which a series that is
+	// no longer collected is dropped.
+	StaleIntervals int
+	// Timestamps adds the collection time to the samples.
+	Timestamps bool
+}
+
+// sample is the last collected value of a series.
+type sample struct {
+	metric prometheus.Metric
+	time   time.Time
+}
+
+// Snapshot implements a prometheus Collector that runs a collector in the
+// background and returns the latest collected metrics.
+type Snapshot struct {
+	collector prometheus.Collector
+	name      string
+	opts      SnapshotOptions
+	now       func() time.Time
+
+	mu          sync.Mutex
+	series      map[string]*sample
+	lastCollect time.Time
+}
+
+// NewSnapshot returns a new Snapshot of the collector.
+func NewSnapshot(collector prometheus.Collector, opts SnapshotOptions) *Snapshot {
+	if opts.StaleIntervals < 1 {
+		opts.StaleIntervals = 1
+	}
+
+	return &Snapshot{
+		collector: collector,
+		name:      reflect.GetType(collector),
+		opts:      opts,
+		now:       time.Now,
+		series:    make(map[string]*sample),
+	}
+}
+
+// Run collects metrics every interval until the context is canceled.
+func (s *Snapshot) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.collect()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Snapshot) collect() {
+	ch := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	collected := make(map[string]*sample)
+	now := s.now()
+
+	go func() {
+		defer close(done)
+
+		for m := range ch {
+			key, ok := seriesKey(m)
+			if !ok {
+				continue
+			}
+
+			collected[key] = &sample{metric: m, time: now}
+		}
+	}()
+
+	s.collector.Collect(ch)
+	close(ch)
+	<-done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, smp := range collected {
+		s.series[key] = smp
+	}
+
+	// Drop the series that are no longer collected.
+	staleness := time.Duration(s.opts.StaleIntervals) * s.opts.Interval
+	for key, smp := range s.series {
+		if now.Sub(smp.time) > staleness {
+			delete(s.series, key)
+		}
+	}
+
+	s.lastCollect = now
+}
+
+// seriesKey returns a key that identifies the series of a metric.
+func seriesKey(m prometheus.Metric) (string, bool) {
+	var pb dto.Metric
+	if err := m.Write(&pb); err != nil {
+		return "", false
+	}
+
+	var key strings.Builder
+	key.WriteString(m.Desc().String())
+
+	for _, lp := range pb.GetLabel() {
+		key.WriteString("\x00" + lp.GetName() + "=" + lp.GetValue())
+	}
+
+	return key.String(), true
+}
+
+// Describe currently does nothing.
+func (s *Snapshot) Describe(_ chan<- *prometheus.Desc) {}
+
+// Collect returns the latest collected metrics.
+func (s *Snapshot) Collect(c chan<- prometheus.Metric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lastCollect.IsZero() {
+		return
+	}
+
+	for _, smp := range s.series {
+		if s.opts.Timestamps {
+			c <- prometheus.NewMetricWithTimestamp(smp.time, smp.metric)
+		} else {
+			c <- smp.metric
+		}
+	}
+
+	c <- prometheus.MustNewConstMetric(
+		snapshotLastCollectDesc,
+		prometheus.GaugeValue,
+		float64(s.lastCollect.Unix()),
+		s.name,
+	)
+}
@@ -0,0 +1,237 @@
+package collector
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var fakeValueDesc = prometheus.NewDesc("livebox_fake_value", "Fake value.", []string{"name"}, nil)
+
+// fakeCollector collects a gauge for each of its values.
+type fakeCollector struct {
+	mu       sync.Mutex
+	values   map[string]float64
+	collects int
+}
+
+func (fc *fakeCollector) set(values map[string]float64) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	fc.values = values
+}
+
+func (fc *fakeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- fakeValueDesc
+}
+
+func (fc *fakeCollector) Collect(ch chan<- prometheus.Metric) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	fc.collects++
+
+	for name, value := range fc.values {
+		ch <- prometheus.MustNewConstMetric(fakeValueDesc, prometheus.GaugeValue, value, name)
+	}
+}
+
+// newTestSnapshot returns a Snapshot of the collector with a fake clock, and a
+// function that advances the clock.
+func newTestSnapshot(c prometheus.Collector, opts SnapshotOptions) (*Snapshot, func(time.Duration)) {
+	now := time.Unix(1700000000, 0)
+
+	s := NewSnapshot(c, opts)
+	s.now = func() time.Time { return now }
+
+	return s, func(d time.Duration) { now = now.Add(d) }
+}
+
+// fakeValues returns the values of livebox_fake_value by name.
+func fakeValues(samples map[string][]testSample) map[string]float64 {
+	values := make(map[string]float64)
+	for _, s := range samples["livebox_fake_value"] {
+		values[s.labels["name"]] = s.value
+	}
+
+	return values
+}
+
+func TestSnapshotBeforeCollect(t *testing.T) {
+	fc := &fakeCollector{values: map[string]float64{"a": 1}}
+	s, _ := newTestSnapshot(fc, SnapshotOptions{Interval: 10 * time.Second})
+
+	if samples := collect(t, s); len(samples) != 0 {
+		t.Errorf("got %v before the first collection, want nothing", samples)
+	}
+
+	if fc.collects != 0 {
+		t.Errorf("the collector was called %d times, want 0", fc.collects)
+	}
+}
+
+func TestSnapshotStaleness(t *testing.T) {
+	fc := &fakeCollector{values: map[string]float64{"a": 1, "b": 2}}
+	s, advance := newTestSnapshot(fc, SnapshotOptions{Interval: 10 * time.Second, StaleIntervals: 2})
+
+	s.collect()
+
+	// b is kept for 2 intervals after its last collection.
+	fc.set(map[string]float64{"a": 3})
+
+	for i := 1; i <= 2; i++ {
+		advance(10 * time.Second)
+		s.collect()
+
+		if got := fakeValues(collect(t, s)); len(got) != 2 || got["a"] != 3 || got["b"] != 2 {
+			t.Errorf("after %d intervals: got %v, want a=3 and b=2", i, got)
+		}
+	}
+
+	advance(10 * time.Second)
+	s.collect()
+
+	if got := fakeValues(collect(t, s)); len(got) != 1 || got["a"] != 3 {
+		t.Errorf("after 3 intervals: got %v, want only a=3", got)
+	}
+
+	// A series collected again is exported again.
+	fc.set(map[string]float64{"a": 4, "b": 5})
+	advance(10 * time.Second)
+	s.collect()
+
+	if got := fakeValues(collect(t, s)); len(got) != 2 || got["a"] != 4 || got["b"] != 5 {
+		t.Errorf("got %v, want a=4 and b=5", got)
+	}
+}
+
+func TestSnapshotDefaultStaleIntervals(t *testing.T) {
+	fc := &fakeCollector{values: map[string]float64{"a": 1}}
+	s, advance := newTestSnapshot(fc, SnapshotOptions{Interval: 10 * time.Second})
+
+	s.collect()
+	fc.set(nil)
+
+	advance(10 * time.Second)
+	s.collect()
+
+	if got := fakeValues(collect(t, s)); len(got) != 1 {
+		t.Errorf("after 1 interval: got %v, want a", got)
+	}
+
+	advance(10 * time.Second)
+	s.collect()
+
+	if got := fakeValues(collect(t, s)); len(got) != 0 {
+		t.Errorf("after 2 intervals: got %v, want nothing", got)
+	}
+}
+
+func TestSnapshotTimestamps(t *testing.T) {
+	for _, timestamps := range []bool{false, true} {
+		fc := &fakeCollector{values: map[string]float64{"a": 1, "b": 2}}
+		s, advance := newTestSnapshot(fc, SnapshotOptions{
+			Interval:       10 * time.Second,
+			StaleIntervals: 3,
+			Timestamps:     timestamps,
+		})
+
+		s.collect()
+		first := s.now()
+
+		fc.set(map[string]float64{"a": 1})
+		advance(10 * time.Second)
+		s.collect()
+		second := s.now()
+
+		want := map[string]int64{"a": second.UnixMilli(), "b": first.UnixMilli()}
+		if !timestamps {
+			want = map[string]int64{"a": 0, "b": 0}
+		}
+
+		samples := collect(t, s)
+
+		for _, smp := range samples["livebox_fake_value"] {
+			if name := smp.labels["name"]; smp.timestampMs != want[name] {
+				t.Errorf("timestamps %t: %s has timestamp %d, want %d", timestamps, name, smp.timestampMs, want[name])
+			}
+		}
+
+		// The last collection time is never timestamped.
+		for _, smp := range samples["livebox_collector_last_collect_timestamp_seconds"] {
+			if smp.timestampMs != 0 {
+				t.Errorf("timestamps %t: last collect has timestamp %d, want 0", timestamps, smp.timestampMs)
+			}
+		}
+	}
+}
+
+func TestSnapshotLastCollect(t *testing.T) {
+	fc := &fakeCollector{}
+	s, advance := newTestSnapshot(fc, SnapshotOptions{Interval: 10 * time.Second})
+
+	for i := 0; i < 2; i++ {
+		s.collect()
+
+		samples := collect(t, s)["livebox_collector_last_collect_timestamp_seconds"]
+		if len(samples) != 1 {
+			t.Fatalf("got %d last collect samples, want 1", len(samples))
+		}
+
+		if got, want := samples[0].value, float64(s.now().Unix()); got != want {
+			t.Errorf("last collect = %v, want %v", got, want)
+		}
+
+		if got := samples[0].labels["collector"]; got != "fakeCollector" {
+			t.Errorf("collector label = %q, want fakeCollector", got)
+		}
+
+		advance(10 * time.Second)
+	}
+}
+
+func TestSnapshotRun(t *testing.T) {
+	fc := &fakeCollector{values: map[string]float64{"a": 1}}
+	s := NewSnapshot(fc, SnapshotOptions{Interval: time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		s.Run(ctx)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		fc.mu.Lock()
+		collects := fc.collects
+		fc.mu.Unlock()
+
+		if collects >= 3 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d collections, want at least 3", collects)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the context was canceled")
+	}
+
+	if got := fakeValues(collect(t, s)); got["a"] != 1 {
+		t.Errorf("got %v, want a=1", got)
+	}
+}
@@ -0,0 +1,165 @@
+// Package dashboard serves a web page that shows the state of the Livebox and
+// of the exporter, refreshed using server-sent events.
+package dashboard
+
+import (
+	_ "embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Tomy2e/livebox-exporter/internal/poller"
+	"github.com/Tomy2e/livebox-exporter/internal/status"
+)
+
+//go:embed index.html
+var indexPage []byte
+
+const redacted = "<redacted>"
+
+// Setting is a configuration setting shown on the dashboard.
+type Setting struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// FlagSettings returns the settings of all flags of a flag set. Credentials
+// and query strings of URLs are redacted, the URLs of the secretURLs flags are
+// redacted down to their scheme and host as their path can contain secrets.
+func FlagSettings(fs *flag.FlagSet, secretURLs ...string) (settings []Setting) {
+	fs.VisitAll(func(f *flag.Flag) {
+		settings = append(settings, Setting{
+			Name:  "-" + f.Name,
+			Value: redactURLs(f.Value.String(), slices.Contains(secretURLs, f.Name)),
+		})
+	})
+
+	return
+}
+
+// EnvSettings returns the settings of the environment variables. The values of
+// secrets are redacted, unset variables are skipped.
+func EnvSettings(names []string, secrets []string) (settings []Setting) {
+	for _, name := range names {
+		if value, ok := os.LookupEnv(name); ok {
+			settings = append(settings, Setting{Name: name, Value: redactURLs(value, false)})
+		}
+	}
+
+	for _, name := range secrets {
+		if _, ok := os.LookupEnv(name); ok {
+			settings = append(settings, Setting{Name: name, Value: redacted})
+		}
+	}
+
+	return
+}
+
+// redactURLs redacts the passwords and query strings of the URLs of a comma
+// separated list, and their path if hostOnly is true. Values that are not
+// URLs are left untouched.
+func redactURLs(value string, hostOnly bool) string {
+	values := strings.Split(value, ",")
+
+	for i, v := range values {
+		u, err := url.Parse(strings.TrimSpace(v))
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			continue
+		}
+
+		if hostOnly {
+			u = &url.URL{Scheme: u.Scheme, Host: u.Host}
+		}
+
+		u.RawQuery = ""
+		u.ForceQuery = false
+		u.Fragment = ""
+		values[i] = u.Redacted()
+	}
+
+	return strings.Join(values, ",")
+}
+
+// Options of the Dashboard.
+type Options struct {
+	// Status returns the state of the Livebox.
+	Status *status.Handler
+	// Monitor returns the state of the pollers.
+	Monitor *poller.Monitor
+	// Config is the configuration of the exporter.
+	Config []Setting
+	// Interval between each refresh of the dashboard.
+	Interval time.Duration
+}
+
+// snapshot is the data sent to the dashboard on each refresh.
+type snapshot struct {
+	Status  *status.Status  `json:"status"`
+	Pollers []poller.Status `json:"pollers"`
+	Config  []Setting       `json:"config"`
+}
+
+// Dashboard serves the dashboard page and its events.
+type Dashboard struct {
+	opts Options
+}
+
+// New returns a new Dashboard.
+func New(opts Options) *Dashboard {
+	return &Dashboard{opts: opts}
+}
+
+// ServeIndex serves the dashboard page.
+func (d *Dashboard) ServeIndex(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(indexPage)
+}
+
+// ServeEvents sends a snapshot of the state of the Livebox and of the
+// exporter as a server-sent event on each refresh, until the client
+// disconnects.
+func (d *Dashboard) ServeEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ticker := time.NewTicker(d.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		data, err := json.Marshal(&snapshot{
+			Status:  d.opts.Status.Status(r.Context()),
+			Pollers: d.opts.Monitor.Statuses(),
+			Config:  d.opts.Config,
+		})
+		if err != nil {
+			log.Printf("WARN: dashboard: failed to encode snapshot: %s", err)
+			return
+		}
+
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return
+		}
+
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,445 @@
+// Package fixture records the Livebox API traffic of the exporter into a
+// fixture file and replays it, so that the exporter can run without a
+// Livebox. Credentials, keys, MAC addresses, public IP addresses and serial
+// numbers are redacted from recorded fixtures.
+package fixture
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const redacted = "redacted"
+
+var (
+	macRegexp   = regexp.MustCompile(`(?i)\b[0-9a-f]{2}(:[0-9a-f]{2}){5}\b`)
+	splitRegexp = regexp.MustCompile(`[^, ]+`)
+)
+
+// documentationNetworks are the networks of the addresses that replace public
+// IP addresses.
+var documentationNetworks = []*net.IPNet{
+	{IP: net.IPv4(203, 0, 113, 0), Mask: net.CIDRMask(24, 32)},
+	{IP: net.ParseIP("2001:db8::"), Mask: net.CIDRMask(32, 128)},
+}
+
+// secretKeys are the parts of the keys whose values are redacted, compared
+// case-insensitively. They match passwords, Wi-Fi keys, PPP and DHCP
+// credentials, session IDs and serial numbers.
+var secretKeys = []string{
+	"password",
+	"passphrase",
+	"presharedkey",
+	"wepkey",
+	"psk",
+	"secret",
+	"token",
+	"username",
+	"sentoption",
+	"authentication",
+	"contextid",
+	"serialnumber",
+	"serialnb",
+	"selfpin",
+}
+
+// ignoredParameters are not used to match requests when replaying.
+var ignoredParameters = []string{"password", "channelid"}
+
+// Exchange is a request to the Livebox API and its response.
+type Exchange struct {
+	Service    string          `json:"service"`
+	Method     string          `json:"method"`
+	Parameters json.RawMessage `json:"parameters,omitempty"`
+	StatusCode int             `json:"statusCode"`
+	Response   json.RawMessage `json:"response"`
+}
+
+// key returns the key used to match requests.
+func (e *Exchange) key() string {
+	return e.Service + "." + e.Method + " " + canonicalParameters(e.Parameters)
+}
+
+type wsRequest struct {
+	Service    string          `json:"service"`
+	Method     string          `json:"method"`
+	Parameters json.RawMessage `json:"parameters"`
+}
+
+// readRequest reads the body of a request and restores it.
+func readRequest(req *http.Request) (*wsRequest, error) {
+	if req.Body == nil {
+		return &wsRequest{}, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	var wsReq wsRequest
+	if err := json.Unmarshal(body, &wsReq); err != nil {
+		return nil, fmt.Errorf("failed to decode request: %w", err)
+	}
+
+	return &wsReq, nil
+}
+
+// canonicalParameters returns the parameters of a request without the ignored
+// parameters, with sorted keys.
+func canonicalParameters(raw json.RawMessage) string {
+	var parameters map[string]any
+	if err := json.Unmarshal(raw, &parameters); err != nil || len(parameters) == 0 {
+		return "{}"
+	}
+
+	for _, name := range ignoredParameters {
+		delete(parameters, name)
+	}
+
+	// Maps are marshaled with sorted keys.
+	data, _ := json.Marshal(parameters)
+
+	return string(data)
+}
+
+// Recorder is an http.RoundTripper that records the Livebox API traffic into
+// a fixture file.
+type Recorder struct {
+	next http.RoundTripper
+
+	mu   sync.Mutex
+	file *os.File
+	macs map[string]string
+	ips  map[string]string
+}
+
+// NewRecorder returns a new Recorder that records the traffic sent with the
+// next round tripper into a new fixture file.
+func NewRecorder(path string, next http.RoundTripper) (*Recorder, error) {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create fixture: %w", err)
+	}
+
+	return &Recorder{
+		next: next,
+		file: file,
+		macs: make(map[string]string),
+		ips:  make(map[string]string),
+	}, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	wsReq, err := readRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err := r.record(wsReq, resp.StatusCode, body); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (r *Recorder) record(req *wsRequest, statusCode int, body []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	exchange := &Exchange{
+		Service:    req.Service,
+		Method:     req.Method,
+		Parameters: r.redact(req.Parameters),
+		StatusCode: statusCode,
+		Response:   r.redact(body),
+	}
+
+	if !json.Valid(exchange.Response) {
+		exchange.Response, _ = json.Marshal(string(exchange.Response))
+	}
+
+	data, err := json.Marshal(exchange)
+	if err != nil {
+		return fmt.Errorf("failed to encode exchange: %w", err)
+	}
+
+	if _, err := r.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+
+	return nil
+}
+
+// redact redacts the values of secret keys, MAC addresses and public IP
+// addresses. MAC addresses are replaced with locally administered addresses
+// and public IP addresses with documentation addresses, the same address is
+// always replaced with the same address. Must be called with the lock held.
+func (r *Recorder) redact(data []byte) []byte {
+	if len(data) == 0 {
+		return data
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value any
+	if decoder.Decode(&value) == nil {
+		if redactedData, err := json.Marshal(r.redactValue("", value, false)); err == nil {
+			data = redactedData
+		}
+	}
+
+	return macRegexp.ReplaceAllFunc(data, func(mac []byte) []byte {
+		key := strings.ToUpper(string(mac))
+
+		replacement, ok := r.macs[key]
+		if !ok {
+			n := len(r.macs) + 1
+			replacement = fmt.Sprintf("02:00:00:%02X:%02X:%02X", n>>16&0xff, n>>8&0xff, n&0xff)
+			r.macs[key] = replacement
+		}
+
+		return []byte(replacement)
+	})
+}
+
+// redactValue redacts a decoded JSON value found under the key. All the
+// strings found under a secret key are redacted.
+func (r *Recorder) redactValue(key string, value any, secret bool) any {
+	secret = secret || isSecretKey(key)
+
+	switch v := value.(type) {
+	case map[string]any:
+		for k, child := range v {
+			v[k] = r.redactValue(k, child, secret)
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = r.redactValue(key, child, secret)
+		}
+	case string:
+		if secret && v != "" {
+			return redacted
+		}
+
+		// Lists of addresses are separated by commas or spaces.
+		return splitRegexp.ReplaceAllStringFunc(v, r.redactIP)
+	}
+
+	return value
+}
+
+// isSecretKey returns true if the values of the key must be redacted.
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// redactIP returns a documentation address in place of a public IP address,
+// optionally followed by a prefix length. Other values are returned as is.
+func (r *Recorder) redactIP(value string) string {
+	addr, prefix, _ := strings.Cut(value, "/")
+
+	ip := net.ParseIP(addr)
+	if ip == nil || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return value
+	}
+
+	for _, network := range documentationNetworks {
+		if network.Contains(ip) {
+			return value
+		}
+	}
+
+	replacement, ok := r.ips[addr]
+	if !ok {
+		n := len(r.ips) + 1
+		if ip.To4() != nil {
+			replacement = fmt.Sprintf("203.0.113.%d", n%254+1)
+		} else {
+			replacement = fmt.Sprintf("2001:db8::%x", n)
+		}
+
+		r.ips[addr] = replacement
+	}
+
+	if prefix != "" {
+		return replacement + "/" + prefix
+	}
+
+	return replacement
+}
+
+// Close closes the fixture file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.file.Close()
+}
+
+// Replayer is an http.RoundTripper that replays the Livebox API traffic of a
+// fixture file.
+type Replayer struct {
+	mu sync.Mutex
+	// exchanges are the recorded exchanges by key, and by service method for
+	// requests whose parameters do not match any recorded request.
+	exchanges map[string][]*Exchange
+	methods   map[string][]*Exchange
+	// next is the index of the next exchange to replay by key.
+	next map[string]int
+}
+
+// NewReplayer returns a new Replayer that replays a fixture file.
+func NewReplayer(path string) (*Replayer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open fixture: %w", err)
+	}
+	defer file.Close()
+
+	rp := &Replayer{
+		exchanges: make(map[string][]*Exchange),
+		methods:   make(map[string][]*Exchange),
+		next:      make(map[string]int),
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64<<20)
+
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var exchange Exchange
+		if err := json.Unmarshal(scanner.Bytes(), &exchange); err != nil {
+			return nil, fmt.Errorf("failed to decode fixture: %w", err)
+		}
+
+		rp.exchanges[exchange.key()] = append(rp.exchanges[exchange.key()], &exchange)
+		rp.methods[exchange.Service+"."+exchange.Method] = append(rp.methods[exchange.Service+"."+exchange.Method], &exchange)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+
+	return rp, nil
+}
+
+// Methods returns the service methods of the fixture, sorted.
+func (rp *Replayer) Methods() []string {
+	methods := make([]string, 0, len(rp.methods))
+	for method := range rp.methods {
+		methods = append(methods, method)
+	}
+
+	sort.Strings(methods)
+
+	return methods
+}
+
+// RoundTrip implements http.RoundTripper. Recorded responses are replayed in
+// order, the last response is repeated once all of them were replayed. Events
+// are not repeated, event requests block until the request is canceled once
+// all of them were replayed.
+func (rp *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	wsReq, err := readRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	exchange := &Exchange{Service: wsReq.Service, Method: wsReq.Method, Parameters: wsReq.Parameters}
+	events := wsReq.Service == "eventmanager"
+
+	rp.mu.Lock()
+	key := exchange.key()
+	exchanges, ok := rp.exchanges[key]
+	if !ok {
+		key = exchange.Service + "." + exchange.Method
+		exchanges, ok = rp.methods[key]
+	}
+
+	var replayed *Exchange
+	if ok {
+		i := rp.next[key]
+		switch {
+		case i < len(exchanges):
+			replayed = exchanges[i]
+			rp.next[key] = i + 1
+		case !events:
+			replayed = exchanges[len(exchanges)-1]
+		}
+	}
+	rp.mu.Unlock()
+
+	switch {
+	case replayed != nil:
+		return newResponse(req, replayed.StatusCode, replayed.Response), nil
+	case events:
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	default:
+		return newResponse(req, http.StatusOK, []byte(fmt.Sprintf(
+			`{"status":null,"errors":[{"error":196618,"description":"Object or parameter not found","info":%q}]}`,
+			exchange.Service+"."+exchange.Method,
+		))), nil
+	}
+}
+
+func newResponse(req *http.Request, statusCode int, body []byte) *http.Response {
+	header := make(http.Header)
+	header.Set("Content-Type", "application/x-sah-ws-4-call+json")
+	header.Add("Set-Cookie", "sessid="+redacted+"; Path=/")
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
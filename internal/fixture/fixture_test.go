@@ -0,0 +1,113 @@
+package fixture
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const mibsResponse = `{"status":{
+	"wlan":{"wl0":{"SSID":"Livebox-1234","Security":{"KeyPassPhrase":"hunter22","PreSharedKey":"abcdef","WEPKey":"12345"},"WPS":{"SelfPIN":"12345670"}}},
+	"ppp":{"ppp_data":{"Username":"fti/abcdef","Password":"secret"}},
+	"dhcp":{"dhcp_data":{"SentOption":{"90":{"Value":"0000000000000000000000001a0900000558010341010d"}}}},
+	"ip":{"ip_data":{"IPAddress":"90.12.34.56","IPv6Address":"2a01:cb00:1234::1/64","DNSServers":"80.10.246.2,81.253.149.9","LANAddress":"192.168.1.1"}},
+	"base":{"veip0":{"MACAddress":"AA:BB:CC:DD:EE:FF","SerialNumber":"AN1234567890","BytesReceived":18446744073709551615}}
+}}`
+
+func TestRecorderRedact(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if bytes.Contains(body, []byte("createContext")) {
+			_, _ = w.Write([]byte(`{"status":0,"data":{"contextID":"ctx-1234"}}`))
+			return
+		}
+
+		_, _ = w.Write([]byte(mibsResponse))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "fixture.jsonl")
+
+	recorder, err := NewRecorder(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{Transport: recorder}
+
+	for _, body := range []string{
+		`{"service":"sah.Device.Information","method":"createContext","parameters":{"applicationName":"webui","username":"admin","password":"hunter2"}}`,
+		`{"service":"NeMo.Intf.data","method":"getMIBs","parameters":{"traverse":"all"}}`,
+	} {
+		resp, err := client.Post(server.URL+"/ws", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The response of the Livebox is not redacted.
+		if data, _ := io.ReadAll(resp.Body); strings.Contains(body, "getMIBs") && string(data) != mibsResponse {
+			t.Errorf("response = %s, want the Livebox response", data)
+		}
+		resp.Body.Close()
+	}
+
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fixture, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, secret := range []string{
+		"hunter2", "ctx-1234", "hunter22", "abcdef", "12345670", "fti/", "secret",
+		"1a0900000558", "90.12.34.56", "2a01:cb00", "80.10.246.2", "81.253.149.9",
+		"AA:BB:CC:DD:EE:FF", "AN1234567890",
+	} {
+		if bytes.Contains(fixture, []byte(secret)) {
+			t.Errorf("fixture contains %q:\n%s", secret, fixture)
+		}
+	}
+
+	for _, kept := range []string{
+		"Livebox-1234", "192.168.1.1", "203.0.113.", "2001:db8::", "/64", "02:00:00:00:00:01",
+		"18446744073709551615",
+	} {
+		if !bytes.Contains(fixture, []byte(kept)) {
+			t.Errorf("fixture does not contain %q:\n%s", kept, fixture)
+		}
+	}
+
+	// The redacted fixture can be replayed.
+	replayer, err := NewReplayer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := (&http.Client{Transport: replayer}).Post(
+		"http://livebox/ws", "application/json",
+		strings.NewReader(`{"service":"NeMo.Intf.data","method":"getMIBs","parameters":{"traverse":"all"}}`),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var replayed struct {
+		Status map[string]any `json:"status"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&replayed); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := replayed.Status["wlan"]; !ok {
+		t.Errorf("replayed response = %v, want the recorded response", replayed.Status)
+	}
+}
@@ -0,0 +1,140 @@
+// Package influx writes the metrics of a Prometheus registry to InfluxDB using
+// the line protocol.
+package influx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/Tomy2e/livebox-exporter/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultTimeout is the timeout of the requests sent to InfluxDB when no HTTP
+// client is specified.
+const defaultTimeout = 10 * time.Second
+
+// Sink receives the serialized metrics.
+type Sink interface {
+	Write(ctx context.Context, lines []byte) error
+}
+
+// HTTPSink writes metrics to the InfluxDB v2 write API.
+type HTTPSink struct {
+	// URL of the InfluxDB server, for example http://localhost:8086.
+	URL         string
+	Org, Bucket string
+	Token       string
+	// HTTPClient used to send requests, defaults to a client with a 10 seconds
+	// timeout.
+	HTTPClient *http.Client
+}
+
+// Write writes metrics to the InfluxDB v2 write API.
+func (s *HTTPSink) Write(ctx context.Context, lines []byte) error {
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return fmt.Errorf("invalid InfluxDB URL: %w", err)
+	}
+
+	u = u.JoinPath("api", "v2", "write")
+	u.RawQuery = url.Values{
+		"org":       {s.Org},
+		"bucket":    {s.Bucket},
+		"precision": {"ns"},
+	}.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(lines))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Token "+s.Token)
+	}
+
+	resp, err := s.httpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to write to InfluxDB: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to write to InfluxDB: unexpected status: %s: %s", resp.Status, body)
+	}
+
+	return nil
+}
+
+// httpClient returns the HTTP client used to send requests.
+func (s *HTTPSink) httpClient() *http.Client {
+	if s.HTTPClient == nil {
+		return &http.Client{Timeout: defaultTimeout}
+	}
+
+	return s.HTTPClient
+}
+
+// FileSink appends metrics to a file, or writes them to stdout if Path is "-".
+type FileSink struct {
+	Path string
+}
+
+// Write appends metrics to the file.
+func (s *FileSink) Write(_ context.Context, lines []byte) error {
+	if s.Path == "-" {
+		_, err := os.Stdout.Write(lines)
+		return err
+	}
+
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open line protocol file: %w", err)
+	}
+
+	if _, err := f.Write(lines); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write line protocol file: %w", err)
+	}
+
+	return f.Close()
+}
+
+// Writer periodically writes the metrics gathered from a Prometheus registry
+// to a sink.
+type Writer struct {
+	gatherer prometheus.Gatherer
+	sink     Sink
+	interval time.Duration
+}
+
+// NewWriter returns a new Writer.
+func NewWriter(gatherer prometheus.Gatherer, sink Sink, interval time.Duration) *Writer {
+	return &Writer{
+		gatherer: gatherer,
+		sink:     sink,
+		interval: interval,
+	}
+}
+
+// Run writes metrics immediately, then every interval until the context is
+// canceled.
+func (w *Writer) Run(ctx context.Context) {
+	metrics.Every(ctx, w.interval, "influx", w.Write)
+}
+
+// Write gathers and writes metrics once.
+func (w *Writer) Write(ctx context.Context) error {
+	families := metrics.Gather(w.gatherer, "influx")
+
+	return w.sink.Write(ctx, encode(families, time.Now()))
+}
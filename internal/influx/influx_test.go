@@ -0,0 +1,98 @@
+package influx
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type chanSink chan []byte
+
+func (s chanSink) Write(_ context.Context, lines []byte) error {
+	s <- lines
+	return nil
+}
+
+func TestWriterRun(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	counter := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "test_bytes_total",
+		Help: "Test counter.",
+	})
+	counter.Add(42)
+	registry.MustRegister(counter)
+
+	sink := make(chanSink, 1)
+	writer := NewWriter(registry, sink, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Metrics are written immediately, without waiting for the interval.
+	go writer.Run(ctx)
+
+	select {
+	case lines := <-sink:
+		if !strings.HasPrefix(string(lines), "test_bytes_total ") {
+			t.Errorf("lines = %q", lines)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("metrics were not written")
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+	bodies := make(chan string, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- r
+		bodies <- string(body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sink := &HTTPSink{URL: server.URL, Org: "home", Bucket: "livebox", Token: "token"}
+	if err := sink.Write(context.Background(), []byte("test value=1 1\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	req := <-requests
+	if req.URL.Path != "/api/v2/write" || req.URL.Query().Get("org") != "home" ||
+		req.URL.Query().Get("bucket") != "livebox" || req.URL.Query().Get("precision") != "ns" {
+		t.Errorf("URL = %s", req.URL)
+	}
+
+	if auth := req.Header.Get("Authorization"); auth != "Token token" {
+		t.Errorf("Authorization = %q, want %q", auth, "Token token")
+	}
+
+	if body := <-bodies; body != "test value=1 1\n" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestHTTPSinkTimeout(t *testing.T) {
+	if timeout := (&HTTPSink{}).httpClient().Timeout; timeout != defaultTimeout {
+		t.Errorf("default timeout = %s, want %s", timeout, defaultTimeout)
+	}
+
+	stuck := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		<-stuck
+	}))
+	defer server.Close()
+	defer close(stuck)
+
+	sink := &HTTPSink{URL: server.URL, HTTPClient: &http.Client{Timeout: 10 * time.Millisecond}}
+	if err := sink.Write(context.Background(), []byte("test value=1 1\n")); err == nil {
+		t.Error("Write succeeded, want a timeout")
+	}
+}
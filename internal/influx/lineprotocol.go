@@ -0,0 +1,107 @@
+package influx
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+)
+
+// field is a field of a point.
+type field struct {
+	key   string
+	value float64
+}
+
+// encode serializes metric families to InfluxDB line protocol. The name of a
+// metric is the measurement and its labels are the tags. Gauges, counters and
+// untyped metrics have a single "value" field, histograms and summaries have
+// "count" and "sum" fields and one field per bucket or quantile.
+func encode(families []*dto.MetricFamily, now time.Time) []byte {
+	var (
+		b         strings.Builder
+		timestamp = strconv.FormatInt(now.UnixNano(), 10)
+	)
+
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			fields := metricFields(mf.GetType(), m)
+			if len(fields) == 0 {
+				continue
+			}
+
+			b.WriteString(measurementEscaper.Replace(mf.GetName()))
+
+			for _, lp := range m.GetLabel() {
+				if lp.GetValue() == "" {
+					continue
+				}
+
+				b.WriteByte(',')
+				b.WriteString(tagEscaper.Replace(lp.GetName()))
+				b.WriteByte('=')
+				b.WriteString(tagEscaper.Replace(lp.GetValue()))
+			}
+
+			for i, f := range fields {
+				if i == 0 {
+					b.WriteByte(' ')
+				} else {
+					b.WriteByte(',')
+				}
+
+				b.WriteString(tagEscaper.Replace(f.key))
+				b.WriteByte('=')
+				b.WriteString(strconv.FormatFloat(f.value, 'g', -1, 64))
+			}
+
+			b.WriteByte(' ')
+			b.WriteString(timestamp)
+			b.WriteByte('\n')
+		}
+	}
+
+	return []byte(b.String())
+}
+
+// metricFields returns the fields of a metric, non-finite values are skipped
+// as they are not supported by InfluxDB.
+func metricFields(t dto.MetricType, m *dto.Metric) (fields []field) {
+	add := func(key string, value float64) {
+		if !math.IsNaN(value) && !math.IsInf(value, 0) {
+			fields = append(fields, field{key: key, value: value})
+		}
+	}
+
+	switch t {
+	case dto.MetricType_COUNTER:
+		add("value", m.GetCounter().GetValue())
+	case dto.MetricType_GAUGE:
+		add("value", m.GetGauge().GetValue())
+	case dto.MetricType_HISTOGRAM:
+		add("count", float64(m.GetHistogram().GetSampleCount()))
+		add("sum", m.GetHistogram().GetSampleSum())
+
+		for _, bucket := range m.GetHistogram().GetBucket() {
+			add(strconv.FormatFloat(bucket.GetUpperBound(), 'g', -1, 64), float64(bucket.GetCumulativeCount()))
+		}
+	case dto.MetricType_SUMMARY:
+		add("count", float64(m.GetSummary().GetSampleCount()))
+		add("sum", m.GetSummary().GetSampleSum())
+
+		for _, q := range m.GetSummary().GetQuantile() {
+			add(strconv.FormatFloat(q.GetQuantile(), 'g', -1, 64), q.GetValue())
+		}
+	default:
+		add("value", m.GetUntyped().GetValue())
+	}
+
+	return
+}
@@ -0,0 +1,209 @@
+package liveboxsim
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Scenario is the initial state of a simulated Livebox and the script of the
+// changes applied to it over time.
+type Scenario struct {
+	Password    string       `json:"password"`
+	Info        Info         `json:"info"`
+	Interfaces  []*Interface `json:"interfaces"`
+	Devices     []*Device    `json:"devices"`
+	ONT         *ONT         `json:"ont,omitempty"`
+	Memory      Memory       `json:"memory"`
+	BootCounter uint64       `json:"bootCounter"`
+	Script      []*Step      `json:"script"`
+	// Start is the time when the simulation starts, the current time if
+	// zero.
+	Start time.Time `json:"start,omitempty"`
+}
+
+// Info identifies the simulated Livebox.
+type Info struct {
+	Manufacturer    string `json:"manufacturer"`
+	ModelName       string `json:"modelName"`
+	ProductClass    string `json:"productClass"`
+	SerialNumber    string `json:"serialNumber"`
+	SoftwareVersion string `json:"softwareVersion"`
+	HardwareVersion string `json:"hardwareVersion"`
+}
+
+// Interface is a network interface of the simulated Livebox. Counters are
+// reported as seen by the Livebox.
+type Interface struct {
+	Name  string `json:"name"`
+	Flags string `json:"flags"`
+	// RxRate and TxRate are the rates of the interface in bytes per second.
+	RxRate float64 `json:"rxRate"`
+	TxRate float64 `json:"txRate"`
+	// Width is the width in bits of the counters, 32 or 64 (default). The
+	// exporter expects all the interfaces to have the same width.
+	Width int `json:"width,omitempty"`
+
+	rxBytes, txBytes     float64
+	rxPackets, txPackets float64
+}
+
+// Device is a device connected to the simulated Livebox.
+type Device struct {
+	Key                    string `json:"key"`
+	Name                   string `json:"name"`
+	DeviceType             string `json:"deviceType"`
+	Active                 bool   `json:"active"`
+	Tags                   string `json:"tags"`
+	IPAddress              string `json:"ipAddress"`
+	Layer2Interface        string `json:"layer2Interface"`
+	SSID                   string `json:"ssid,omitempty"`
+	OperatingFrequencyBand string `json:"operatingFrequencyBand,omitempty"`
+	Manufacturer           string `json:"manufacturer,omitempty"`
+	// RxRate and TxRate are the rates of the device in bytes per second, as
+	// seen by the device.
+	RxRate float64 `json:"rxRate"`
+	TxRate float64 `json:"txRate"`
+
+	firstSeen, lastConnection time.Time
+	rxBytes, txBytes          float64
+}
+
+// ONT contains the readings of the GPON ONT, returned for the veip0 interface.
+type ONT struct {
+	Temperature        float64 `json:"temperature"`
+	DownstreamCurrRate float64 `json:"downstreamCurrRate"`
+	UpstreamCurrRate   float64 `json:"upstreamCurrRate"`
+}
+
+// Memory is the memory status of the simulated Livebox, in kB.
+type Memory struct {
+	Total float64 `json:"total"`
+	Free  float64 `json:"free"`
+}
+
+// Actions of the script steps.
+const (
+	// ActionReboot resets all counters and the uptime.
+	ActionReboot = "reboot"
+	// ActionResetCounters resets the counters of an interface.
+	ActionResetCounters = "reset_counters"
+	// ActionSetRate changes the rates of an interface or of a device.
+	ActionSetRate = "set_rate"
+	// ActionFail makes the next Count requests to a service method fail, or
+	// all requests if Count is negative.
+	ActionFail = "fail"
+	// ActionRecover stops the failures of a service method.
+	ActionRecover = "recover"
+	// ActionConnect and ActionDisconnect change the status of a device.
+	ActionConnect    = "connect"
+	ActionDisconnect = "disconnect"
+	// ActionExpireSessions invalidates all sessions, clients must log in
+	// again.
+	ActionExpireSessions = "expire_sessions"
+)
+
+// Step is a change applied to the simulated Livebox.
+type Step struct {
+	// At is the time of the step since the start of the simulation.
+	At        Duration `json:"at"`
+	Action    string   `json:"action"`
+	Interface string   `json:"interface,omitempty"`
+	MAC       string   `json:"mac,omitempty"`
+	Service   string   `json:"service,omitempty"`
+	Method    string   `json:"method,omitempty"`
+	Count     int      `json:"count,omitempty"`
+	RxRate    float64  `json:"rxRate,omitempty"`
+	TxRate    float64  `json:"txRate,omitempty"`
+}
+
+// Duration is a time.Duration encoded as a string in JSON.
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// LoadScenario loads a scenario from a JSON file.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario: %w", err)
+	}
+
+	var scenario Scenario
+	if err := json.Unmarshal(data, &scenario); err != nil {
+		return nil, fmt.Errorf("failed to decode scenario: %w", err)
+	}
+
+	return &scenario, nil
+}
+
+// DefaultScenario returns a scenario simulating a Livebox 5 with a GPON WAN
+// interface, an Ethernet port and two Wi-Fi access points.
+func DefaultScenario() *Scenario {
+	return &Scenario{
+		Password: "admin",
+		Info: Info{
+			Manufacturer:    "Sagemcom",
+			ModelName:       "SagemcomFast5656",
+			ProductClass:    "Livebox 5",
+			SerialNumber:    "SIM000000000",
+			SoftwareVersion: "SG30_sip-fr-6.62.12.1",
+			HardwareVersion: "SGFB5656",
+		},
+		Interfaces: []*Interface{
+			{Name: "veip0", Flags: "gpon wan statmon enabled netdev", RxRate: 12_500_000, TxRate: 2_500_000, Width: 32},
+			{Name: "eth1", Flags: "eth lan statmon enabled netdev", RxRate: 1_000_000, TxRate: 8_000_000, Width: 32},
+			{Name: "wl0", Flags: "wlanvap statmon enabled netdev", RxRate: 1_500_000, TxRate: 4_500_000, Width: 32},
+			{Name: "wl1", Flags: "wlanvap statmon enabled netdev", RxRate: 0, TxRate: 0, Width: 32},
+		},
+		Devices: []*Device{
+			{
+				Key: "AA:BB:CC:00:00:01", Name: "desktop", DeviceType: "Computer", Active: true,
+				Tags: "lan edev mac physical eth ipv4 ipv6", IPAddress: "192.168.1.10", Layer2Interface: "eth1",
+				RxRate: 8_000_000, TxRate: 1_000_000,
+			},
+			{
+				Key: "AA:BB:CC:00:00:02", Name: "phone", DeviceType: "Mobile", Active: true,
+				Tags: "lan edev mac physical wifi ipv4 ipv6", IPAddress: "192.168.1.11", Layer2Interface: "wl0",
+				SSID: "Livebox-SIM", OperatingFrequencyBand: "5GHz",
+				RxRate: 4_500_000, TxRate: 1_500_000,
+			},
+			{
+				Key: "AA:BB:CC:00:00:03", Name: "printer", DeviceType: "Printer", Active: false,
+				Tags: "lan edev mac physical wifi ipv4", IPAddress: "192.168.1.12", Layer2Interface: "wl1",
+				SSID: "Livebox-SIM", OperatingFrequencyBand: "2.4GHz",
+			},
+		},
+		ONT: &ONT{
+			Temperature:        48,
+			DownstreamCurrRate: 2_000_000,
+			UpstreamCurrRate:   1_000_000,
+		},
+		Memory: Memory{
+			Total: 1_000_000,
+			Free:  400_000,
+		},
+		BootCounter: 3,
+	}
+}
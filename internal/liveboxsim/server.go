@@ -0,0 +1,382 @@
+package liveboxsim
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// eventsTimeout is the maximum duration of a get_events long poll.
+const eventsTimeout = 10 * time.Second
+
+// Livebox error codes.
+const (
+	errorPermissionDenied = 13
+	errorNotFound         = 196618
+	errorSimulated        = 1
+)
+
+type wsRequest struct {
+	Service    string         `json:"service"`
+	Method     string         `json:"method"`
+	Parameters map[string]any `json:"parameters"`
+}
+
+type wsError struct {
+	Error       int    `json:"error"`
+	Description string `json:"description"`
+	Info        string `json:"info"`
+}
+
+type wsResponse struct {
+	Status any       `json:"status"`
+	Data   any       `json:"data,omitempty"`
+	Errors []wsError `json:"errors,omitempty"`
+}
+
+// ServeHTTP implements the /ws endpoint of the Livebox.
+func (s *Simulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/ws" {
+		http.NotFound(w, r)
+		return
+	}
+
+	var req wsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if r.Header.Get("Authorization") == "X-Sah-Login" {
+		s.login(w, &req)
+		return
+	}
+
+	if !s.authenticated(r) {
+		writeResponse(w, http.StatusUnauthorized, &wsResponse{Errors: []wsError{{
+			Error:       errorPermissionDenied,
+			Description: "Permission denied",
+		}}})
+		return
+	}
+
+	if req.Service == "eventmanager" && req.Method == "get_events" {
+		s.getEvents(w, r, &req)
+		return
+	}
+
+	status, ok := s.call(&req)
+	if !ok {
+		writeResponse(w, http.StatusOK, &wsResponse{Errors: []wsError{{
+			Error:       errorNotFound,
+			Description: "Object or parameter not found",
+			Info:        req.Service + "." + req.Method,
+		}}})
+		return
+	}
+
+	if err, ok := status.(*wsError); ok {
+		writeResponse(w, http.StatusOK, &wsResponse{Errors: []wsError{*err}})
+		return
+	}
+
+	writeResponse(w, http.StatusOK, &wsResponse{Status: status})
+}
+
+func (s *Simulator) login(w http.ResponseWriter, req *wsRequest) {
+	if req.Service != "sah.Device.Information" || req.Method != "createContext" ||
+		req.Parameters["password"] != s.scenario.Password {
+		writeResponse(w, http.StatusUnauthorized, &wsResponse{Errors: []wsError{{
+			Error:       errorPermissionDenied,
+			Description: "Permission denied",
+		}}})
+		return
+	}
+
+	contextID := randomID()
+	sessionID := randomID()
+
+	s.mu.Lock()
+	s.sessions[contextID] = true
+	s.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: "sessid", Value: sessionID, Path: "/"})
+	writeResponse(w, http.StatusOK, &wsResponse{
+		Status: 0,
+		Data: map[string]any{
+			"contextID": contextID,
+			"username":  req.Parameters["username"],
+			"groups":    "http,admin",
+		},
+	})
+}
+
+// authenticated returns true if the request has a valid context ID.
+func (s *Simulator) authenticated(r *http.Request) bool {
+	contextID := r.Header.Get("X-Context")
+	if contextID == "" {
+		contextID = strings.TrimPrefix(r.Header.Get("Authorization"), "X-Sah ")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.sessions[contextID]
+}
+
+// call returns the status of a request, a *wsError if the request fails or
+// false if the service method does not exist.
+func (s *Simulator) call(req *wsRequest) (any, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fail(req.Service, req.Method) {
+		return &wsError{
+			Error:       errorSimulated,
+			Description: "Simulated error",
+			Info:        req.Service + "." + req.Method,
+		}, true
+	}
+
+	switch {
+	case req.Service == "DeviceInfo" && req.Method == "get":
+		return s.deviceInfo(), true
+	case req.Service == "DeviceInfo.MemoryStatus" && req.Method == "get":
+		return map[string]any{"Total": s.scenario.Memory.Total, "Free": s.scenario.Memory.Free}, true
+	case req.Service == "NMC.Reboot" && req.Method == "get":
+		return map[string]any{"BootCounter": s.scenario.BootCounter}, true
+	case req.Service == "Devices" && req.Method == "get":
+		return s.devices(), true
+	case req.Service == "HomeLan" && req.Method == "getResults":
+		return s.homeLanResults(), true
+	case req.Service == "HomeLan" && req.Method == "getWANCounters":
+		return s.wanCounters()
+	case req.Service == "NeMo.Intf.data" && req.Method == "getMIBs":
+		return s.mibs(), true
+	case strings.HasPrefix(req.Service, "HomeLan.Interface.") && strings.HasSuffix(req.Service, ".Stats") && req.Method == "get":
+		itf := s.findInterface(strings.TrimSuffix(strings.TrimPrefix(req.Service, "HomeLan.Interface."), ".Stats"))
+		if itf == nil {
+			return nil, false
+		}
+
+		return map[string]any{
+			"BytesReceived":   counter(itf.rxBytes, itf.Width),
+			"BytesSent":       counter(itf.txBytes, itf.Width),
+			"PacketsReceived": counter(itf.rxPackets, itf.Width),
+			"PacketsSent":     counter(itf.txPackets, itf.Width),
+		}, true
+	case strings.HasPrefix(req.Service, "NeMo.Intf."):
+		itf := s.findInterface(strings.TrimPrefix(req.Service, "NeMo.Intf."))
+		if itf == nil {
+			return nil, false
+		}
+
+		return s.nemo(itf, req.Method)
+	default:
+		return nil, false
+	}
+}
+
+func (s *Simulator) deviceInfo() map[string]any {
+	info := s.scenario.Info
+
+	return map[string]any{
+		"Manufacturer":    info.Manufacturer,
+		"ModelName":       info.ModelName,
+		"ProductClass":    info.ProductClass,
+		"SerialNumber":    info.SerialNumber,
+		"SoftwareVersion": info.SoftwareVersion,
+		"HardwareVersion": info.HardwareVersion,
+		"UpTime":          int64(s.uptime().Seconds()),
+	}
+}
+
+func (s *Simulator) devices() []map[string]any {
+	devices := make([]map[string]any, 0, len(s.scenario.Devices))
+
+	for _, dev := range s.scenario.Devices {
+		var lastConnection string
+		if !dev.lastConnection.IsZero() {
+			lastConnection = dev.lastConnection.UTC().Format(time.RFC3339)
+		}
+
+		devices = append(devices, map[string]any{
+			"Key":                    dev.Key,
+			"PhysAddress":            dev.Key,
+			"Name":                   dev.Name,
+			"DeviceType":             dev.DeviceType,
+			"Active":                 dev.Active,
+			"Tags":                   dev.Tags,
+			"IPAddress":              dev.IPAddress,
+			"Layer2Interface":        dev.Layer2Interface,
+			"SSID":                   dev.SSID,
+			"OperatingFrequencyBand": dev.OperatingFrequencyBand,
+			"Manufacturer":           dev.Manufacturer,
+			"FirstSeen":              dev.firstSeen.UTC().Format(time.RFC3339),
+			"LastConnection":         lastConnection,
+			"IPv6Address":            []any{},
+		})
+	}
+
+	return devices
+}
+
+// homeLanResults returns the traffic of the interfaces in bits per 30 seconds.
+func (s *Simulator) homeLanResults() map[string]any {
+	results := make(map[string]any, len(s.scenario.Interfaces))
+
+	for _, itf := range s.scenario.Interfaces {
+		results[itf.Name] = map[string]any{
+			"Traffic": []map[string]any{{
+				"Timestamp":  s.now().Unix(),
+				"Rx_Counter": int(itf.RxRate * 8 * 30),
+				"Tx_Counter": int(itf.TxRate * 8 * 30),
+			}},
+		}
+	}
+
+	return results
+}
+
+func (s *Simulator) wanCounters() (any, bool) {
+	for _, itf := range s.scenario.Interfaces {
+		if strings.Contains(itf.Flags, "wan") {
+			return map[string]any{
+				"BytesReceived":   counter(itf.rxBytes, itf.Width),
+				"BytesSent":       counter(itf.txBytes, itf.Width),
+				"PacketsReceived": counter(itf.rxPackets, itf.Width),
+				"PacketsSent":     counter(itf.txPackets, itf.Width),
+			}, true
+		}
+	}
+
+	return nil, false
+}
+
+// mibs returns the enabled interfaces.
+func (s *Simulator) mibs() map[string]any {
+	base := make(map[string]any, len(s.scenario.Interfaces))
+
+	for _, itf := range s.scenario.Interfaces {
+		if strings.Contains(itf.Flags, "enabled") {
+			base[itf.Name] = map[string]any{"flags": itf.Flags}
+		}
+	}
+
+	return map[string]any{"base": base}
+}
+
+func (s *Simulator) nemo(itf *Interface, method string) (any, bool) {
+	switch method {
+	case "get":
+		if itf.Name != "veip0" || s.scenario.ONT == nil {
+			return map[string]any{"Name": itf.Name, "Flags": itf.Flags}, true
+		}
+
+		return map[string]any{
+			"Name":               itf.Name,
+			"Flags":              itf.Flags,
+			"Temperature":        s.scenario.ONT.Temperature,
+			"DownstreamCurrRate": s.scenario.ONT.DownstreamCurrRate,
+			"UpstreamCurrRate":   s.scenario.ONT.UpstreamCurrRate,
+		}, true
+	case "getNetDevStats":
+		return map[string]any{
+			"RxBytes":   counter(itf.rxBytes, itf.Width),
+			"TxBytes":   counter(itf.txBytes, itf.Width),
+			"RxPackets": counter(itf.rxPackets, itf.Width),
+			"TxPackets": counter(itf.txPackets, itf.Width),
+		}, true
+	case "getSSIDStats":
+		return map[string]any{
+			"BytesReceived":   counter(itf.rxBytes, itf.Width),
+			"BytesSent":       counter(itf.txBytes, itf.Width),
+			"PacketsReceived": counter(itf.rxPackets, itf.Width),
+			"PacketsSent":     counter(itf.txPackets, itf.Width),
+		}, true
+	case "getStationStats":
+		// Station stats are reported as seen by the access point.
+		stations := []map[string]any{}
+
+		for _, dev := range s.scenario.Devices {
+			if dev.Active && dev.Layer2Interface == itf.Name {
+				stations = append(stations, map[string]any{
+					"MACAddress": dev.Key,
+					"RxBytes":    uint64(dev.txBytes),
+					"TxBytes":    uint64(dev.rxBytes),
+				})
+			}
+		}
+
+		return stations, true
+	default:
+		return nil, false
+	}
+}
+
+// getEvents long-polls the events of a channel. A new channel is created if
+// the channel ID is unknown.
+func (s *Simulator) getEvents(w http.ResponseWriter, r *http.Request, req *wsRequest) {
+	channelID, _ := req.Parameters["channelid"].(float64)
+
+	s.mu.Lock()
+	ch, ok := s.channels[int(channelID)]
+	if !ok {
+		s.nextID++
+		channelID = float64(s.nextID)
+		ch = &channel{queue: make(chan *event, eventsBuffer)}
+
+		if events, ok := req.Parameters["events"].([]any); ok {
+			for _, e := range events {
+				if e, ok := e.(string); ok {
+					ch.events = append(ch.events, e)
+				}
+			}
+		}
+
+		s.channels[int(channelID)] = ch
+	}
+	s.mu.Unlock()
+
+	type eventData struct {
+		Data *event `json:"data"`
+	}
+
+	events := []eventData{}
+	timer := time.NewTimer(eventsTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-r.Context().Done():
+		return
+	case <-timer.C:
+	case evt := <-ch.queue:
+		events = append(events, eventData{Data: evt})
+
+		// Drain the queued events.
+		for len(ch.queue) > 0 {
+			events = append(events, eventData{Data: <-ch.queue})
+		}
+	}
+
+	writeResponse(w, http.StatusOK, map[string]any{
+		"channelid": int(channelID),
+		"events":    events,
+	})
+}
+
+func writeResponse(w http.ResponseWriter, code int, resp any) {
+	w.Header().Set("Content-Type", "application/x-sah-ws-4-call+json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,305 @@
+// Package liveboxsim simulates the Livebox sysbus JSON API used by the
+// exporter, so that the exporter can be run and tested without a Livebox.
+//
+// The simulator follows the protocol implemented by
+// github.com/Tomy2e/livebox-api-client: requests are POSTed to /ws, a session
+// is created using the X-Sah-Login authorization and events are long-polled
+// using the eventmanager service.
+package liveboxsim
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// averagePacketSize is used to derive packet counters from byte counters.
+const averagePacketSize = 1000
+
+// eventsBuffer is the maximum number of events queued per channel.
+const eventsBuffer = 1000
+
+// Simulator is a simulated Livebox.
+type Simulator struct {
+	mu       sync.Mutex
+	scenario *Scenario
+	// start is the time of the start of the simulation, elapsed is the
+	// simulated time since then and boot is the value of elapsed at the last
+	// reboot.
+	start    time.Time
+	boot     time.Duration
+	elapsed  time.Duration
+	script   []*Step
+	failures map[string]int
+	sessions map[string]bool
+	channels map[int]*channel
+	nextID   int
+}
+
+// channel is an event channel of a client.
+type channel struct {
+	events []string
+	queue  chan *event
+}
+
+// event is a Livebox event.
+type event struct {
+	Handler string `json:"handler"`
+	Object  struct {
+		Reason     string         `json:"reason"`
+		Attributes map[string]any `json:"attributes"`
+	} `json:"object"`
+}
+
+// New returns a new Simulator running the scenario.
+func New(scenario *Scenario) *Simulator {
+	now := scenario.Start
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	for _, dev := range scenario.Devices {
+		dev.firstSeen = now.Add(-24 * time.Hour)
+
+		if dev.Active {
+			dev.lastConnection = now.Add(-time.Hour)
+		}
+	}
+
+	return &Simulator{
+		scenario: scenario,
+		start:    now,
+		script:   append([]*Step(nil), scenario.Script...),
+		failures: make(map[string]int),
+		sessions: make(map[string]bool),
+		channels: make(map[int]*channel),
+	}
+}
+
+// Run advances the simulation every tick until the context is canceled.
+func (s *Simulator) Run(ctx context.Context, tick time.Duration) {
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Advance(tick)
+		}
+	}
+}
+
+// Advance advances the simulation: counters increase according to the rates
+// and the steps of the script that are due are applied.
+func (s *Simulator) Advance(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.elapsed += d
+	seconds := d.Seconds()
+
+	for _, itf := range s.scenario.Interfaces {
+		itf.rxBytes += itf.RxRate * seconds
+		itf.txBytes += itf.TxRate * seconds
+		itf.rxPackets += itf.RxRate * seconds / averagePacketSize
+		itf.txPackets += itf.TxRate * seconds / averagePacketSize
+	}
+
+	statistics := make(map[string]any)
+
+	for _, dev := range s.scenario.Devices {
+		if !dev.Active {
+			continue
+		}
+
+		dev.rxBytes += dev.RxRate * seconds
+		dev.txBytes += dev.TxRate * seconds
+
+		if !strings.Contains(dev.Tags, "wifi") {
+			statistics[dev.Key] = map[string]any{
+				"RxBytes": uint64(dev.rxBytes),
+				"TxBytes": uint64(dev.txBytes),
+			}
+		}
+	}
+
+	if len(statistics) > 0 {
+		s.emit("Devices.Device.lan", "Statistics", statistics)
+	}
+
+	for len(s.script) > 0 && time.Duration(s.script[0].At) <= s.elapsed {
+		step := s.script[0]
+		s.script = s.script[1:]
+
+		if err := s.apply(step); err != nil {
+			log.Printf("WARN: livebox-sim: script step %q failed: %s", step.Action, err)
+		}
+	}
+}
+
+// Apply applies a step immediately, its time is ignored.
+func (s *Simulator) Apply(step *Step) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.apply(step)
+}
+
+// apply applies a step. Must be called with the lock held.
+func (s *Simulator) apply(step *Step) error {
+	switch step.Action {
+	case ActionReboot:
+		s.scenario.BootCounter++
+		s.boot = s.elapsed
+		s.sessions = make(map[string]bool)
+
+		for _, itf := range s.scenario.Interfaces {
+			itf.rxBytes, itf.txBytes, itf.rxPackets, itf.txPackets = 0, 0, 0, 0
+		}
+
+		for _, dev := range s.scenario.Devices {
+			dev.rxBytes, dev.txBytes = 0, 0
+		}
+	case ActionResetCounters:
+		itf := s.findInterface(step.Interface)
+		if itf == nil {
+			return fmt.Errorf("unknown interface: %s", step.Interface)
+		}
+
+		itf.rxBytes, itf.txBytes, itf.rxPackets, itf.txPackets = 0, 0, 0, 0
+	case ActionSetRate:
+		if step.MAC != "" {
+			dev := s.findDevice(step.MAC)
+			if dev == nil {
+				return fmt.Errorf("unknown device: %s", step.MAC)
+			}
+
+			dev.RxRate, dev.TxRate = step.RxRate, step.TxRate
+
+			return nil
+		}
+
+		itf := s.findInterface(step.Interface)
+		if itf == nil {
+			return fmt.Errorf("unknown interface: %s", step.Interface)
+		}
+
+		itf.RxRate, itf.TxRate = step.RxRate, step.TxRate
+	case ActionFail:
+		count := step.Count
+		if count == 0 {
+			count = 1
+		}
+
+		s.failures[step.Service+"/"+step.Method] = count
+	case ActionRecover:
+		delete(s.failures, step.Service+"/"+step.Method)
+	case ActionConnect, ActionDisconnect:
+		dev := s.findDevice(step.MAC)
+		if dev == nil {
+			return fmt.Errorf("unknown device: %s", step.MAC)
+		}
+
+		active := step.Action == ActionConnect
+		if dev.Active == active {
+			return nil
+		}
+
+		dev.Active = active
+		if active {
+			dev.lastConnection = s.now()
+		}
+
+		s.emit("Devices.Device."+dev.Key, "changed", map[string]any{"Active": active})
+	case ActionExpireSessions:
+		s.sessions = make(map[string]bool)
+	default:
+		return fmt.Errorf("unknown action: %s", step.Action)
+	}
+
+	return nil
+}
+
+// now returns the current simulated time. Must be called with the lock held.
+func (s *Simulator) now() time.Time {
+	return s.start.Add(s.elapsed)
+}
+
+// uptime returns the simulated uptime. Must be called with the lock held.
+func (s *Simulator) uptime() time.Duration {
+	return s.elapsed - s.boot + 24*time.Hour
+}
+
+func (s *Simulator) findInterface(name string) *Interface {
+	for _, itf := range s.scenario.Interfaces {
+		if itf.Name == name {
+			return itf
+		}
+	}
+
+	return nil
+}
+
+func (s *Simulator) findDevice(mac string) *Device {
+	for _, dev := range s.scenario.Devices {
+		if strings.EqualFold(dev.Key, mac) {
+			return dev
+		}
+	}
+
+	return nil
+}
+
+// emit sends an event to the channels subscribed to its handler. Events are
+// dropped when a channel is full. Must be called with the lock held.
+func (s *Simulator) emit(handler, reason string, attributes map[string]any) {
+	evt := &event{Handler: handler}
+	evt.Object.Reason = reason
+	evt.Object.Attributes = attributes
+
+	for _, ch := range s.channels {
+		for _, prefix := range ch.events {
+			if strings.HasPrefix(handler, prefix) {
+				select {
+				case ch.queue <- evt:
+				default:
+				}
+
+				break
+			}
+		}
+	}
+}
+
+// fail returns true if a request to a service method must fail. Must be
+// called with the lock held.
+func (s *Simulator) fail(service, method string) bool {
+	key := service + "/" + method
+
+	count, ok := s.failures[key]
+	switch {
+	case !ok:
+		return false
+	case count > 1:
+		s.failures[key] = count - 1
+	case count == 1:
+		delete(s.failures, key)
+	}
+
+	return true
+}
+
+// counter returns the value of a counter, wrapped according to the width.
+func counter(value float64, width int) uint64 {
+	if width == 32 {
+		return uint64(math.Mod(value, 1<<32))
+	}
+
+	return uint64(value)
+}
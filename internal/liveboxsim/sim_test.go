@@ -0,0 +1,185 @@
+package liveboxsim_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Tomy2e/livebox-exporter/internal/collector"
+	"github.com/Tomy2e/livebox-exporter/internal/liveboxsim"
+	"github.com/Tomy2e/livebox-exporter/internal/poller"
+	exporterLivebox "github.com/Tomy2e/livebox-exporter/pkg/livebox"
+	"github.com/Tomy2e/livebox-exporter/pkg/oui"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// value returns the value of the metric with the specified labels, or false
+// if the metric does not exist.
+func value(t *testing.T, gatherer prometheus.Gatherer, name string, labels map[string]string) (float64, bool) {
+	t.Helper()
+
+	families, err := gatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if want, ok := labels[label.GetName()]; ok && want != label.GetValue() {
+					continue metrics
+				}
+			}
+
+			return metric.GetGauge().GetValue() + metric.GetCounter().GetValue(), true
+		}
+	}
+
+	return 0, false
+}
+
+// TestSimulator runs the interface discovery, the HomeLan and NetDev pollers
+// and the Devices collector against the simulator.
+func TestSimulator(t *testing.T) {
+	sim := liveboxsim.New(liveboxsim.DefaultScenario())
+
+	server := httptest.NewServer(sim)
+	defer server.Close()
+	// Interrupt the events long polls, Close waits for them otherwise.
+	defer server.CloseClientConnections()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	client, err := exporterLivebox.NewClient("admin", server.URL, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	interfaces, err := exporterLivebox.DiscoverInterfaces(ctx, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make(map[string]*exporterLivebox.Interface)
+	for _, itf := range interfaces {
+		names[itf.Name] = itf
+	}
+
+	for _, name := range []string{"veip0", "eth1", "wl0", "wl1"} {
+		if _, ok := names[name]; !ok {
+			t.Fatalf("interface %s was not discovered, got %v", name, names)
+		}
+	}
+
+	if !names["veip0"].IsWAN() || !names["wl0"].IsWLAN() {
+		t.Errorf("unexpected interface flags: veip0=%q wl0=%q", names["veip0"].Flags, names["wl0"].Flags)
+	}
+
+	t.Run("pollers", func(t *testing.T) {
+		// The pollers export the same counters with a different source.
+		pollers := poller.Pollers{
+			poller.NewInterfaceHomeLanMbits(client, interfaces),
+			poller.NewInterfaceNetDevMbits(client, interfaces),
+		}
+
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(pollers.Collectors()...)
+
+		poll := func() {
+			t.Helper()
+
+			for _, p := range pollers {
+				if err := p.Poll(ctx); err != nil {
+					t.Fatalf("%s: %s", poller.Name(p), err)
+				}
+			}
+		}
+
+		rxBytes := func(source string) float64 {
+			t.Helper()
+
+			labels := map[string]string{"interface": "eth1", "source": source}
+
+			v, ok := value(t, registry, "livebox_interface_rx_bytes_total", labels)
+			if !ok {
+				t.Fatalf("livebox_interface_rx_bytes_total%v is missing", labels)
+			}
+
+			return v
+		}
+
+		// eth1 is a LAN interface, its counters are swapped: the bytes
+		// received by the Livebox are the bytes sent by the devices, at
+		// 8 MB/s.
+		poll()
+		sim.Advance(10 * time.Second)
+		poll()
+
+		for _, source := range []string{"homelan", "netdev"} {
+			if got := rxBytes(source); got != 80_000_000 {
+				t.Fatalf("%s rx bytes = %g, want 80000000", source, got)
+			}
+		}
+
+		// The counters of the interface are reset, the exported counter
+		// keeps increasing.
+		if err := sim.Apply(&liveboxsim.Step{Action: liveboxsim.ActionResetCounters, Interface: "eth1"}); err != nil {
+			t.Fatal(err)
+		}
+
+		sim.Advance(5 * time.Second)
+		poll()
+
+		for _, source := range []string{"homelan", "netdev"} {
+			if got := rxBytes(source); got != 120_000_000 {
+				t.Errorf("%s rx bytes after reset = %g, want 120000000", source, got)
+			}
+		}
+
+		if _, ok := value(t, registry, "livebox_interface_poll_errors_total", nil); ok {
+			t.Error("poll errors were counted")
+		}
+	})
+
+	t.Run("devices", func(t *testing.T) {
+		devices := collector.NewDevices(client, interfaces, collector.WithContext(ctx), collector.WithOUIDatabase(&oui.Database{}))
+
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(devices)
+
+		for mac, want := range map[string]float64{
+			"AA:BB:CC:00:00:01": 1,
+			"AA:BB:CC:00:00:03": 0,
+		} {
+			if got, ok := value(t, registry, "livebox_device_active", map[string]string{"mac": mac}); !ok || got != want {
+				t.Errorf("livebox_device_active{mac=%q} = %g, %t, want %g", mac, got, ok, want)
+			}
+		}
+
+		// The traffic of Ethernet devices is reported by events, advance the
+		// simulation until the events observer received them.
+		desktop := map[string]string{"mac": "AA:BB:CC:00:00:01"}
+		deadline := time.Now().Add(10 * time.Second)
+
+		for {
+			sim.Advance(time.Second)
+
+			if v, ok := value(t, registry, "livebox_device_rx_bytes_total", desktop); ok && v > 0 {
+				break
+			}
+
+			if time.Now().After(deadline) {
+				t.Fatal("the traffic of the desktop was not reported")
+			}
+
+			time.Sleep(100 * time.Millisecond)
+		}
+	})
+}
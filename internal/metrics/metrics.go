@@ -0,0 +1,56 @@
+// Package metrics contains helpers shared by the components that read the
+// metrics gathered by the exporter.
+package metrics
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Gather gathers the metrics of the gatherer. Gather errors are partial, they
+// are logged with the specified component name and what was gathered is
+// returned.
+func Gather(gatherer prometheus.Gatherer, component string) []*dto.MetricFamily {
+	families, err := gatherer.Gather()
+	if err != nil {
+		log.Printf("WARN: %s: gather failed: %s", component, err)
+	}
+
+	return families
+}
+
+// Every calls fn immediately, then at every interval until the context is
+// canceled. Errors returned by fn are logged with the specified component
+// name.
+func Every(ctx context.Context, interval time.Duration, component string, fn func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := fn(ctx); err != nil {
+			log.Printf("WARN: %s: %s", component, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Value returns the value of a gauge, counter or untyped metric.
+func Value(m *dto.Metric) float64 {
+	switch {
+	case m.GetGauge() != nil:
+		return m.GetGauge().GetValue()
+	case m.GetCounter() != nil:
+		return m.GetCounter().GetValue()
+	default:
+		return m.GetUntyped().GetValue()
+	}
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestEvery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	calls := 0
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		// fn is called immediately, the interval never elapses.
+		Every(ctx, time.Hour, "test", func(context.Context) error {
+			calls++
+			cancel()
+
+			return errors.New("failed")
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Every did not return after the context was canceled")
+	}
+
+	if calls != 1 {
+		t.Errorf("fn was called %d times, want 1", calls)
+	}
+}
+
+func TestGather(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_gauge", Help: "Test gauge."})
+	gauge.Set(1)
+
+	registry.MustRegister(gauge)
+
+	// A failing gatherer, the gathered metrics are still returned.
+	failing := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return nil, errors.New("failed")
+	})
+
+	families := Gather(prometheus.Gatherers{registry, failing}, "test")
+	if len(families) != 1 || families[0].GetName() != "test_gauge" {
+		t.Fatalf("Gather() = %v, want test_gauge", families)
+	}
+
+	if v := Value(families[0].GetMetric()[0]); v != 1 {
+		t.Errorf("Value() = %g, want 1", v)
+	}
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/Tomy2e/livebox-api-client/api/request"
+	exporterLivebox "github.com/Tomy2e/livebox-exporter/pkg/livebox"
+)
+
+// fakeAPI is a Livebox API whose requests are handled by a function.
+type fakeAPI struct {
+	request func(ctx context.Context, req *request.Request) (any, error)
+	events  chan *exporterLivebox.EventMessage
+	session exporterLivebox.SessionInfo
+}
+
+func (fa *fakeAPI) Request(ctx context.Context, req *request.Request, out any) error {
+	response, err := fa.request(ctx, req)
+	if err != nil || out == nil {
+		return err
+	}
+
+	b, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, out)
+}
+
+func (fa *fakeAPI) Events(context.Context, []string) <-chan *exporterLivebox.EventMessage {
+	return fa.events
+}
+
+func (fa *fakeAPI) Session() exporterLivebox.SessionInfo {
+	return fa.session
+}
@@ -0,0 +1,157 @@
+// Package middleware contains middlewares for the Livebox API.
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/Tomy2e/livebox-api-client/api/request"
+	exporterLivebox "github.com/Tomy2e/livebox-exporter/pkg/livebox"
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultCacheTTLs are the default TTLs of the responses of the methods called
+// when Prometheus scrapes the exporter.
+var DefaultCacheTTLs = map[string]time.Duration{
+	"Devices.get":                 10 * time.Second,
+	"DeviceInfo.get":              10 * time.Second,
+	"DeviceInfo.MemoryStatus.get": 10 * time.Second,
+	"NMC.Reboot.get":              10 * time.Second,
+	"NeMo.Intf.veip0.get":         10 * time.Second,
+}
+
+// requestTimeout bounds the requests shared by concurrent callers, as they
+// are not bound to the context of a caller.
+const requestTimeout = 30 * time.Second
+
+// cacheEntry is a cached response.
+type cacheEntry struct {
+	response json.RawMessage
+	expires  time.Time
+}
+
+// cache deduplicates concurrent identical requests and caches responses.
+type cache struct {
+	next    exporterLivebox.RequestFunc
+	ttls    map[string]time.Duration
+	timeout time.Duration
+	group   singleflight.Group
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+// Cache returns a middleware that collapses concurrent identical requests
+// into one request and caches successful responses. TTLs are configured by
+// service method ("<service>.<method>"), patterns are matched using
+// path.Match. Responses of methods without a TTL are not cached but
+// concurrent requests are still collapsed.
+func Cache(ttls map[string]time.Duration) exporterLivebox.Middleware {
+	return exporterLivebox.RequestMiddleware(func(next exporterLivebox.RequestFunc) exporterLivebox.RequestFunc {
+		c := &cache{
+			next:    next,
+			ttls:    ttls,
+			timeout: requestTimeout,
+			entries: make(map[string]*cacheEntry),
+		}
+
+		return c.request
+	})
+}
+
+// ttl returns the TTL of the responses of a service method.
+func (c *cache) ttl(req *request.Request) time.Duration {
+	name := req.Service + "." + req.Method
+
+	if ttl, ok := c.ttls[name]; ok {
+		return ttl
+	}
+
+	for pattern, ttl := range c.ttls {
+		if ok, _ := path.Match(pattern, name); ok {
+			return ttl
+		}
+	}
+
+	return 0
+}
+
+func (c *cache) request(ctx context.Context, req *request.Request, out any) error {
+	parameters, err := json.Marshal(req.Parameters)
+	if err != nil {
+		return fmt.Errorf("failed to encode parameters: %w", err)
+	}
+
+	key := req.Service + "\x00" + req.Method + "\x00" + string(parameters)
+	ttl := c.ttl(req)
+
+	if response, ok := c.get(key); ok {
+		return json.Unmarshal(response, out)
+	}
+
+	// The request is not bound to the context of the first caller, so that
+	// other callers are not affected if it is canceled, but it is bounded by
+	// a timeout so that a hung request does not block the later callers.
+	ch := c.group.DoChan(key, func() (any, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+		defer cancel()
+
+		var response json.RawMessage
+
+		if err := c.next(ctx, req, &response); err != nil {
+			return nil, err
+		}
+
+		if ttl > 0 {
+			c.set(key, response, ttl)
+		}
+
+		return response, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case result := <-ch:
+		if result.Err != nil {
+			return result.Err
+		}
+
+		return json.Unmarshal(result.Val.(json.RawMessage), out)
+	}
+}
+
+func (c *cache) get(key string) (json.RawMessage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+
+	return entry.response, true
+}
+
+func (c *cache) set(key string, response json.RawMessage, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	// Remove expired entries.
+	for k, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+
+	c.entries[key] = &cacheEntry{
+		response: response,
+		expires:  now.Add(ttl),
+	}
+}
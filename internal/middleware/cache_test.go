@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Tomy2e/livebox-api-client/api/request"
+	exporterLivebox "github.com/Tomy2e/livebox-exporter/pkg/livebox"
+)
+
+// countingAPI returns a fake API that counts its requests and replies with the
+// number of requests.
+func countingAPI(calls *atomic.Int32) *fakeAPI {
+	return &fakeAPI{request: func(context.Context, *request.Request) (any, error) {
+		return calls.Add(1), nil
+	}}
+}
+
+func get(t *testing.T, api exporterLivebox.API, service string, parameters map[string]any) int32 {
+	t.Helper()
+
+	var out int32
+	if err := api.Request(context.Background(), request.New(service, "get", parameters), &out); err != nil {
+		t.Fatal(err)
+	}
+
+	return out
+}
+
+func TestCacheTTL(t *testing.T) {
+	var calls atomic.Int32
+
+	api := exporterLivebox.Chain(countingAPI(&calls), Cache(map[string]time.Duration{
+		"Devices.get": 100 * time.Millisecond,
+	}))
+
+	if got := get(t, api, "Devices", nil); got != 1 {
+		t.Fatalf("first response = %d, want 1", got)
+	}
+
+	// The response is cached.
+	if got := get(t, api, "Devices", nil); got != 1 {
+		t.Errorf("cached response = %d, want 1", got)
+	}
+
+	// Requests with other parameters are cached separately.
+	if got := get(t, api, "Devices", map[string]any{"expression": "lan"}); got != 2 {
+		t.Errorf("response with parameters = %d, want 2", got)
+	}
+
+	// Methods without a TTL are not cached.
+	if got := get(t, api, "DeviceInfo", nil); got != 3 {
+		t.Errorf("response without TTL = %d, want 3", got)
+	}
+
+	if got := get(t, api, "DeviceInfo", nil); got != 4 {
+		t.Errorf("second response without TTL = %d, want 4", got)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+
+	// The cached response expired.
+	if got := get(t, api, "Devices", nil); got != 5 {
+		t.Errorf("response after expiry = %d, want 5", got)
+	}
+}
+
+func TestCacheErrorsNotCached(t *testing.T) {
+	var calls atomic.Int32
+
+	api := exporterLivebox.Chain(&fakeAPI{request: func(context.Context, *request.Request) (any, error) {
+		if calls.Add(1) == 1 {
+			return nil, errors.New("failed")
+		}
+
+		return "ok", nil
+	}}, Cache(map[string]time.Duration{"Devices.get": time.Hour}))
+
+	var out string
+	if err := api.Request(context.Background(), request.New("Devices", "get", nil), &out); err == nil {
+		t.Fatal("the error was not returned")
+	}
+
+	if err := api.Request(context.Background(), request.New("Devices", "get", nil), &out); err != nil || out != "ok" {
+		t.Errorf("response after an error = %q, %v, want ok", out, err)
+	}
+}
+
+func TestCacheSingleflight(t *testing.T) {
+	var calls atomic.Int32
+
+	release := make(chan struct{})
+
+	api := exporterLivebox.Chain(&fakeAPI{request: func(context.Context, *request.Request) (any, error) {
+		<-release
+		return calls.Add(1), nil
+	}}, Cache(nil))
+
+	var wg sync.WaitGroup
+
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if got := get(t, api, "Devices", nil); got != 1 {
+				t.Errorf("response = %d, want 1", got)
+			}
+		}()
+	}
+
+	// Let the callers join the request in flight.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("%d requests were sent, want 1", n)
+	}
+}
+
+// TestCacheTimeout checks that a hung request times out even if the caller
+// has no deadline.
+func TestCacheTimeout(t *testing.T) {
+	api := &fakeAPI{request: func(ctx context.Context, _ *request.Request) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+
+	c := &cache{next: api.Request, timeout: 50 * time.Millisecond, entries: make(map[string]*cacheEntry)}
+
+	done := make(chan error, 1)
+	go func() {
+		var out any
+		done <- c.request(context.Background(), request.New("Devices", "get", nil), &out)
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("error = %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request did not time out")
+	}
+}
+
+func TestCachePatterns(t *testing.T) {
+	c := &cache{ttls: map[string]time.Duration{
+		"Devices.get":     time.Second,
+		"NeMo.Intf.*.get": 2 * time.Second,
+		"DeviceInfo.*":    3 * time.Second,
+	}}
+
+	for name, want := range map[string]time.Duration{
+		"Devices.get":                 time.Second,
+		"NeMo.Intf.veip0.get":         2 * time.Second,
+		"NeMo.Intf.veip0.getStats":    0,
+		"DeviceInfo.get":              3 * time.Second,
+		"DeviceInfo.MemoryStatus.get": 3 * time.Second,
+		"Devices.getResults":          0,
+	} {
+		i := strings.LastIndex(name, ".")
+		service, method := name[:i], name[i+1:]
+
+		if got := c.ttl(request.New(service, method, nil)); got != want {
+			t.Errorf("ttl(%s) = %s, want %s", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/Tomy2e/livebox-api-client/api/request"
+	exporterLivebox "github.com/Tomy2e/livebox-exporter/pkg/livebox"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Results of the Livebox API requests.
+const (
+	resultSuccess  = "success"
+	resultError    = "error"
+	resultCanceled = "canceled"
+)
+
+// Instrumentation records metrics about the Livebox API requests, sessions and
+// events.
+type Instrumentation struct {
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	events          prometheus.Counter
+	eventErrors     prometheus.Counter
+	logins          prometheus.CounterFunc
+	lastLogin       prometheus.GaugeFunc
+
+	mu  sync.Mutex
+	api exporterLivebox.API
+}
+
+// NewInstrumentation returns a new Instrumentation.
+func NewInstrumentation() *Instrumentation {
+	i := &Instrumentation{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "livebox_api_requests_total",
+			Help: "Number of requests sent to the Livebox API.",
+		}, []string{"service", "method", "result"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "livebox_api_request_duration_seconds",
+			Help:    "Duration of the requests sent to the Livebox API.",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 15},
+		}, []string{"service", "method"}),
+		events: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "livebox_api_events_total",
+			Help: "Number of events received from the Livebox API.",
+		}),
+		eventErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "livebox_api_event_stream_errors_total",
+			Help: "Number of errors on the event streams of the Livebox API.",
+		}),
+	}
+
+	i.logins = prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name: "livebox_api_logins_total",
+		Help: "Number of sessions created on the Livebox, including renewals of expired sessions.",
+	}, func() float64 {
+		return float64(i.session().Logins)
+	})
+
+	i.lastLogin = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "livebox_api_last_login_timestamp_seconds",
+		Help: "Time when the last session was created on the Livebox.",
+	}, func() float64 {
+		if lastLogin := i.session().LastLogin; !lastLogin.IsZero() {
+			return float64(lastLogin.Unix())
+		}
+
+		return 0
+	})
+
+	return i
+}
+
+// Collectors returns the metrics of the instrumentation.
+func (i *Instrumentation) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		i.requests,
+		i.requestDuration,
+		i.events,
+		i.eventErrors,
+		i.logins,
+		i.lastLogin,
+	}
+}
+
+func (i *Instrumentation) session() exporterLivebox.SessionInfo {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.api == nil {
+		return exporterLivebox.SessionInfo{}
+	}
+
+	return i.api.Session()
+}
+
+// Middleware returns the middleware that instruments the API.
+func (i *Instrumentation) Middleware() exporterLivebox.Middleware {
+	return func(next exporterLivebox.API) exporterLivebox.API {
+		i.mu.Lock()
+		i.api = next
+		i.mu.Unlock()
+
+		return &instrumentedAPI{API: next, instrumentation: i}
+	}
+}
+
+type instrumentedAPI struct {
+	exporterLivebox.API
+	instrumentation *Instrumentation
+}
+
+func (ia *instrumentedAPI) Request(ctx context.Context, req *request.Request, out any) error {
+	timer := prometheus.NewTimer(ia.instrumentation.requestDuration.WithLabelValues(req.Service, req.Method))
+	err := ia.API.Request(ctx, req, out)
+	timer.ObserveDuration()
+
+	result := resultSuccess
+
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		result = resultCanceled
+	case err != nil:
+		result = resultError
+	}
+
+	ia.instrumentation.requests.WithLabelValues(req.Service, req.Method, result).Inc()
+
+	return err
+}
+
+func (ia *instrumentedAPI) Events(ctx context.Context, channels []string) <-chan *exporterLivebox.EventMessage {
+	events := make(chan *exporterLivebox.EventMessage)
+
+	go func() {
+		defer close(events)
+
+		for msg := range ia.API.Events(ctx, channels) {
+			if msg.Error != nil {
+				ia.instrumentation.eventErrors.Inc()
+			} else {
+				ia.instrumentation.events.Inc()
+			}
+
+			select {
+			case events <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events
+}
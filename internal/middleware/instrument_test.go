@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Tomy2e/livebox-api-client/api/request"
+	exporterLivebox "github.com/Tomy2e/livebox-exporter/pkg/livebox"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// gather returns the values of the metrics of the registry, keyed by name
+// followed by the label values sorted by label name.
+func gather(t *testing.T, registry *prometheus.Registry) map[string]float64 {
+	t.Helper()
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := make(map[string]float64)
+
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			key := family.GetName()
+			for _, label := range m.GetLabel() {
+				key += "," + label.GetValue()
+			}
+
+			values[key] = m.GetCounter().GetValue() + m.GetGauge().GetValue() + float64(m.GetHistogram().GetSampleCount())
+		}
+	}
+
+	return values
+}
+
+func TestInstrumentationRequests(t *testing.T) {
+	lastLogin := time.Unix(1700000000, 0)
+
+	api := &fakeAPI{
+		request: func(ctx context.Context, req *request.Request) (any, error) {
+			switch req.Method {
+			case "fail":
+				return nil, errors.New("failed")
+			case "wait":
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+
+			return nil, nil
+		},
+		session: exporterLivebox.SessionInfo{Logins: 3, LastLogin: lastLogin},
+	}
+
+	i := NewInstrumentation()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(i.Collectors()...)
+
+	client := exporterLivebox.Chain(api, i.Middleware())
+
+	_ = client.Request(context.Background(), request.New("Devices", "get", nil), nil)
+	_ = client.Request(context.Background(), request.New("Devices", "get", nil), nil)
+	_ = client.Request(context.Background(), request.New("Devices", "fail", nil), nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_ = client.Request(ctx, request.New("Devices", "wait", nil), nil)
+
+	values := gather(t, registry)
+
+	for key, want := range map[string]float64{
+		"livebox_api_requests_total,get,success,Devices":    2,
+		"livebox_api_requests_total,fail,error,Devices":     1,
+		"livebox_api_requests_total,wait,canceled,Devices":  1,
+		"livebox_api_request_duration_seconds,get,Devices":  2,
+		"livebox_api_request_duration_seconds,fail,Devices": 1,
+		"livebox_api_logins_total":                          3,
+		"livebox_api_last_login_timestamp_seconds":          float64(lastLogin.Unix()),
+	} {
+		if got := values[key]; got != want {
+			t.Errorf("%s = %g, want %g", key, got, want)
+		}
+	}
+}
+
+func TestInstrumentationEvents(t *testing.T) {
+	api := &fakeAPI{events: make(chan *exporterLivebox.EventMessage, 3)}
+	api.events <- &exporterLivebox.EventMessage{Event: &exporterLivebox.Event{Handler: "Devices.Device"}}
+	api.events <- &exporterLivebox.EventMessage{Error: errors.New("failed")}
+	api.events <- &exporterLivebox.EventMessage{Event: &exporterLivebox.Event{Handler: "Devices.Device"}}
+	close(api.events)
+
+	i := NewInstrumentation()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(i.Collectors()...)
+
+	client := exporterLivebox.Chain(api, i.Middleware())
+
+	received := 0
+	for range client.Events(context.Background(), []string{"Devices.Device"}) {
+		received++
+	}
+
+	if received != 3 {
+		t.Errorf("received %d messages, want 3", received)
+	}
+
+	values := gather(t, registry)
+
+	if got := values["livebox_api_events_total"]; got != 2 {
+		t.Errorf("livebox_api_events_total = %g, want 2", got)
+	}
+
+	if got := values["livebox_api_event_stream_errors_total"]; got != 1 {
+		t.Errorf("livebox_api_event_stream_errors_total = %g, want 1", got)
+	}
+}
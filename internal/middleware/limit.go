@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Tomy2e/livebox-api-client/api/request"
+	exporterLivebox "github.com/Tomy2e/livebox-exporter/pkg/livebox"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/semaphore"
+)
+
+// LimiterOptions of the Limiter.
+type LimiterOptions struct {
+	// Rate is the maximum number of requests per second, requests are not
+	// rate limited if zero.
+	Rate float64
+	// Burst is the maximum number of requests sent at once when the rate
+	// limit was not reached recently.
+	Burst int
+	// MaxInFlight is the maximum number of concurrent requests, the number of
+	// concurrent requests is not limited if zero.
+	MaxInFlight int
+}
+
+// Limiter limits the rate and concurrency of the requests sent to the
+// Livebox. Events are not limited.
+type Limiter struct {
+	bucket   *tokenBucket
+	inFlight *semaphore.Weighted
+
+	waitSeconds      prometheus.Histogram
+	inFlightRequests prometheus.Gauge
+}
+
+// NewLimiter returns a new Limiter.
+func NewLimiter(opts LimiterOptions) *Limiter {
+	l := &Limiter{
+		waitSeconds: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "livebox_api_limiter_wait_seconds",
+			Help:    "Time spent by Livebox API requests waiting for the rate and concurrency limits.",
+			Buckets: []float64{0.001, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		}),
+		inFlightRequests: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "livebox_api_in_flight_requests",
+			Help: "Number of Livebox API requests in flight.",
+		}),
+	}
+
+	if opts.Rate > 0 {
+		burst := float64(opts.Burst)
+		if burst < 1 {
+			burst = 1
+		}
+
+		l.bucket = &tokenBucket{
+			rate:   opts.Rate,
+			burst:  burst,
+			tokens: burst,
+			last:   time.Now(),
+		}
+	}
+
+	if opts.MaxInFlight > 0 {
+		l.inFlight = semaphore.NewWeighted(int64(opts.MaxInFlight))
+	}
+
+	return l
+}
+
+// Collectors returns the metrics of the limiter.
+func (l *Limiter) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{l.waitSeconds, l.inFlightRequests}
+}
+
+// Middleware returns the middleware that limits requests.
+func (l *Limiter) Middleware() exporterLivebox.Middleware {
+	return exporterLivebox.RequestMiddleware(func(next exporterLivebox.RequestFunc) exporterLivebox.RequestFunc {
+		return func(ctx context.Context, req *request.Request, out any) error {
+			start := time.Now()
+
+			if l.inFlight != nil {
+				if err := l.inFlight.Acquire(ctx, 1); err != nil {
+					return err
+				}
+				defer l.inFlight.Release(1)
+			}
+
+			if l.bucket != nil {
+				if err := l.bucket.wait(ctx); err != nil {
+					return err
+				}
+			}
+
+			l.waitSeconds.Observe(time.Since(start).Seconds())
+
+			l.inFlightRequests.Inc()
+			defer l.inFlightRequests.Dec()
+
+			return next(ctx, req, out)
+		}
+	})
+}
+
+// tokenBucket is a token bucket rate limiter.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// wait waits until a token is available and takes it.
+func (tb *tokenBucket) wait(ctx context.Context) error {
+	delay := tb.reserve()
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		tb.cancel()
+		return ctx.Err()
+	}
+}
+
+// reserve takes a token and returns the delay before it is available.
+func (tb *tokenBucket) reserve() time.Duration {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	now := time.Now()
+
+	tb.tokens += now.Sub(tb.last).Seconds() * tb.rate
+	if tb.tokens > tb.burst {
+		tb.tokens = tb.burst
+	}
+
+	tb.last = now
+	tb.tokens--
+
+	if tb.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-tb.tokens / tb.rate * float64(time.Second))
+}
+
+// cancel gives back a token that was reserved.
+func (tb *tokenBucket) cancel() {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	tb.tokens++
+}
@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Tomy2e/livebox-api-client/api/request"
+	exporterLivebox "github.com/Tomy2e/livebox-exporter/pkg/livebox"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestTokenBucket(t *testing.T) {
+	tb := &tokenBucket{rate: 10, burst: 2, tokens: 2, last: time.Now()}
+
+	// The burst is available immediately.
+	for i := range 2 {
+		if delay := tb.reserve(); delay > 0 {
+			t.Fatalf("reservation %d was delayed by %s", i, delay)
+		}
+	}
+
+	// The next token is available after 1/rate.
+	if delay := tb.reserve(); delay < 90*time.Millisecond || delay > 100*time.Millisecond {
+		t.Errorf("delay = %s, want about 100ms", delay)
+	}
+
+	// A canceled reservation gives back its token.
+	tb.cancel()
+
+	if delay := tb.reserve(); delay < 90*time.Millisecond || delay > 100*time.Millisecond {
+		t.Errorf("delay after cancel = %s, want about 100ms", delay)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := tb.wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("wait() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestLimiterRate(t *testing.T) {
+	l := NewLimiter(LimiterOptions{Rate: 20, Burst: 1})
+
+	api := exporterLivebox.Chain(&fakeAPI{request: func(context.Context, *request.Request) (any, error) {
+		return nil, nil
+	}}, l.Middleware())
+
+	start := time.Now()
+
+	for range 3 {
+		if err := api.Request(context.Background(), request.New("Devices", "get", nil), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first request uses the burst, the others wait 50ms each.
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("3 requests took %s, want at least 100ms", elapsed)
+	}
+
+	m := &dto.Metric{}
+	if err := l.waitSeconds.Write(m); err != nil {
+		t.Fatal(err)
+	}
+
+	if count := m.GetHistogram().GetSampleCount(); count != 3 {
+		t.Errorf("wait observations = %d, want 3", count)
+	}
+}
+
+func TestLimiterMaxInFlight(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+
+	l := NewLimiter(LimiterOptions{MaxInFlight: 2})
+
+	api := exporterLivebox.Chain(&fakeAPI{request: func(context.Context, *request.Request) (any, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+
+		for {
+			current := maxInFlight.Load()
+			if n <= current || maxInFlight.CompareAndSwap(current, n) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+
+		return nil, nil
+	}}, l.Middleware())
+
+	var wg sync.WaitGroup
+
+	for range 6 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if err := api.Request(context.Background(), request.New("Devices", "get", nil), nil); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if n := maxInFlight.Load(); n != 2 {
+		t.Errorf("maximum number of concurrent requests = %d, want 2", n)
+	}
+
+	// Requests waiting for a slot stop when their context is canceled.
+	started, release := make(chan struct{}), make(chan struct{})
+	blocked := exporterLivebox.Chain(&fakeAPI{request: func(context.Context, *request.Request) (any, error) {
+		close(started)
+		<-release
+		return nil, nil
+	}}, NewLimiter(LimiterOptions{MaxInFlight: 1}).Middleware())
+
+	go func() { _ = blocked.Request(context.Background(), request.New("Devices", "get", nil), nil) }()
+	defer close(release)
+
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := blocked.Request(ctx, request.New("Devices", "get", nil), nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,210 @@
+package mqtt
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"time"
+)
+
+// MQTT 3.1.1 control packet types.
+const (
+	packetConnect    = 0x10
+	packetConnack    = 0x20
+	packetPublish    = 0x30
+	packetDisconnect = 0xe0
+)
+
+const (
+	connectFlagCleanSession = 0x02
+	connectFlagPassword     = 0x40
+	connectFlagUsername     = 0x80
+	publishFlagRetain       = 0x01
+)
+
+const dialTimeout = 10 * time.Second
+
+// ClientOptions configures the MQTT client.
+type ClientOptions struct {
+	// Broker URL, the scheme is either tcp (or mqtt) or ssl (or mqtts, tls).
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+}
+
+// Validate checks the options. MQTT 3.1.1 does not allow a password
+// without a username.
+func (o ClientOptions) Validate() error {
+	if o.Password != "" && o.Username == "" {
+		return errors.New("a username is required when a password is set")
+	}
+
+	return nil
+}
+
+// Client is a minimal MQTT 3.1.1 client that publishes messages with QoS 0.
+// This implementation is not thread-safe.
+type Client struct {
+	conn net.Conn
+}
+
+// Dial connects to the MQTT broker.
+func Dial(ctx context.Context, opts ClientOptions) (*Client, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(opts.Broker)
+	if err != nil {
+		return nil, fmt.Errorf("invalid broker URL: %w", err)
+	}
+
+	dialer := &net.Dialer{Timeout: dialTimeout}
+
+	var conn net.Conn
+
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		conn, err = dialer.DialContext(ctx, "tcp", hostPort(u, "1883"))
+	case "ssl", "tls", "mqtts":
+		conn, err = (&tls.Dialer{NetDialer: dialer}).DialContext(ctx, "tcp", hostPort(u, "8883"))
+	default:
+		return nil, fmt.Errorf("unsupported broker URL scheme: %s", u.Scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to broker: %w", err)
+	}
+
+	c := &Client{conn: conn}
+
+	if err := c.connect(opts); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func hostPort(u *url.URL, defaultPort string) string {
+	if u.Port() == "" {
+		return net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+
+	return u.Host
+}
+
+func (c *Client) connect(opts ClientOptions) error {
+	var (
+		body  bytes.Buffer
+		flags byte = connectFlagCleanSession
+	)
+
+	if opts.Username != "" {
+		flags |= connectFlagUsername
+	}
+
+	if opts.Password != "" {
+		flags |= connectFlagPassword
+	}
+
+	writeString(&body, "MQTT")
+	// Protocol level 4 (3.1.1), connect flags and keep alive disabled.
+	body.Write([]byte{4, flags, 0, 0})
+	writeString(&body, opts.ClientID)
+
+	if opts.Username != "" {
+		writeString(&body, opts.Username)
+	}
+
+	if opts.Password != "" {
+		writeString(&body, opts.Password)
+	}
+
+	if err := c.write(packetConnect, body.Bytes()); err != nil {
+		return fmt.Errorf("failed to send CONNECT: %w", err)
+	}
+
+	_ = c.conn.SetReadDeadline(time.Now().Add(dialTimeout))
+	defer c.conn.SetReadDeadline(time.Time{})
+
+	connack := make([]byte, 4)
+	if _, err := io.ReadFull(c.conn, connack); err != nil {
+		return fmt.Errorf("failed to read CONNACK: %w", err)
+	}
+
+	if connack[0] != packetConnack {
+		return errors.New("unexpected packet received instead of CONNACK")
+	}
+
+	if connack[3] != 0 {
+		return fmt.Errorf("connection refused by broker (return code %d)", connack[3])
+	}
+
+	return nil
+}
+
+// Publish publishes a message with QoS 0.
+func (c *Client) Publish(topic string, payload []byte, retain bool) error {
+	var (
+		body   bytes.Buffer
+		header byte = packetPublish
+	)
+
+	if retain {
+		header |= publishFlagRetain
+	}
+
+	writeString(&body, topic)
+	body.Write(payload)
+
+	if err := c.write(header, body.Bytes()); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", topic, err)
+	}
+
+	return nil
+}
+
+// Close disconnects from the broker.
+func (c *Client) Close() error {
+	_ = c.write(packetDisconnect, nil)
+
+	return c.conn.Close()
+}
+
+func (c *Client) write(header byte, body []byte) error {
+	packet := []byte{header}
+
+	// Remaining length is encoded as a variable length integer.
+	length := len(body)
+	for {
+		b := byte(length % 128)
+		length /= 128
+
+		if length > 0 {
+			b |= 0x80
+		}
+
+		packet = append(packet, b)
+
+		if length == 0 {
+			break
+		}
+	}
+
+	_ = c.conn.SetWriteDeadline(time.Now().Add(dialTimeout))
+
+	_, err := c.conn.Write(append(packet, body...))
+	return err
+}
+
+func writeString(b *bytes.Buffer, s string) {
+	_ = binary.Write(b, binary.BigEndian, uint16(len(s)))
+	b.WriteString(s)
+}
@@ -0,0 +1,103 @@
+package mqtt
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// broker accepts one connection, reads the CONNECT packet, accepts it and
+// sends the connect flags to the returned channel.
+func broker(t *testing.T) (string, <-chan byte) {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lis.Close() })
+
+	flags := make(chan byte, 1)
+
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		// Fixed header and a single byte remaining length.
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			t.Error(err)
+			return
+		}
+
+		body := make([]byte, header[1])
+		if _, err := io.ReadFull(conn, body); err != nil {
+			t.Error(err)
+			return
+		}
+
+		// Protocol name "MQTT" (6 bytes), then the protocol level.
+		flags <- body[7]
+
+		_, _ = conn.Write([]byte{packetConnack, 2, 0, 0})
+		_, _ = io.Copy(io.Discard, conn)
+	}()
+
+	return "tcp://" + lis.Addr().String(), flags
+}
+
+func TestDial(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for name, tc := range map[string]struct {
+		opts  ClientOptions
+		flags byte
+	}{
+		"anonymous": {
+			opts:  ClientOptions{ClientID: "test"},
+			flags: connectFlagCleanSession,
+		},
+		"username": {
+			opts:  ClientOptions{ClientID: "test", Username: "user"},
+			flags: connectFlagCleanSession | connectFlagUsername,
+		},
+		"password": {
+			opts:  ClientOptions{ClientID: "test", Username: "user", Password: "secret"},
+			flags: connectFlagCleanSession | connectFlagUsername | connectFlagPassword,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			addr, flags := broker(t)
+
+			tc.opts.Broker = addr
+
+			client, err := Dial(ctx, tc.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			if got := <-flags; got != tc.flags {
+				t.Errorf("connect flags = %#x, want %#x", got, tc.flags)
+			}
+		})
+	}
+}
+
+func TestDialPasswordWithoutUsername(t *testing.T) {
+	_, err := Dial(context.Background(), ClientOptions{
+		// The broker is never contacted.
+		Broker:   "tcp://127.0.0.1:1",
+		ClientID: "test",
+		Password: "secret",
+	})
+	if err == nil {
+		t.Fatal("Dial() succeeded with a password and no username")
+	}
+}
@@ -0,0 +1,201 @@
+// Package mqtt publishes the values collected by the exporter to an MQTT
+// broker, with Home Assistant MQTT discovery support.
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Tomy2e/livebox-exporter/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// sensor is a value published as a Home Assistant sensor.
+type sensor struct {
+	// metric is the name of the Prometheus metric of the sensor.
+	metric      string
+	id          string
+	name        string
+	unit        string
+	deviceClass string
+}
+
+var sensors = []sensor{
+	{metric: "livebox_wan_rx_mbits", id: "wan_rx_mbits", name: "WAN download", unit: "Mbit/s", deviceClass: "data_rate"},
+	{metric: "livebox_wan_tx_mbits", id: "wan_tx_mbits", name: "WAN upload", unit: "Mbit/s", deviceClass: "data_rate"},
+	{metric: "livebox_ont_temperature_celsius", id: "ont_temperature", name: "ONT temperature", unit: "°C", deviceClass: "temperature"},
+	{metric: "livebox_deviceinfo_uptime_seconds_total", id: "uptime", name: "Uptime", unit: "s", deviceClass: "duration"},
+}
+
+// deviceActiveMetric is the metric used to publish the presence of devices.
+const deviceActiveMetric = "livebox_device_active"
+
+// Options of the Publisher.
+type Options struct {
+	Client ClientOptions
+	// TopicPrefix is the prefix of the topics where values are published.
+	TopicPrefix string
+	// DiscoveryPrefix is the Home Assistant discovery prefix, discovery
+	// payloads are not published if empty.
+	DiscoveryPrefix string
+	// Interval between each publication.
+	Interval time.Duration
+}
+
+// Publisher periodically publishes the values gathered from a Prometheus
+// registry to an MQTT broker.
+type Publisher struct {
+	gatherer prometheus.Gatherer
+	opts     Options
+	client   *Client
+	// discovered contains the entities for which a discovery payload was
+	// published on the current connection.
+	discovered map[string]bool
+}
+
+// NewPublisher returns a new Publisher.
+func NewPublisher(gatherer prometheus.Gatherer, opts Options) *Publisher {
+	return &Publisher{
+		gatherer:   gatherer,
+		opts:       opts,
+		discovered: make(map[string]bool),
+	}
+}
+
+// Run publishes values until the context is canceled.
+func (p *Publisher) Run(ctx context.Context) {
+	metrics.Every(ctx, p.opts.Interval, "mqtt", func(ctx context.Context) error {
+		err := p.publish(ctx)
+		if err != nil && p.client != nil {
+			// Reconnect on the next publication.
+			_ = p.client.Close()
+			p.client = nil
+		}
+
+		return err
+	})
+
+	if p.client != nil {
+		_ = p.client.Close()
+	}
+}
+
+func (p *Publisher) publish(ctx context.Context) error {
+	families := metrics.Gather(p.gatherer, "mqtt")
+
+	if p.client == nil {
+		var err error
+		if p.client, err = Dial(ctx, p.opts.Client); err != nil {
+			return err
+		}
+
+		clear(p.discovered)
+	}
+
+	byName := make(map[string]*dto.MetricFamily, len(families))
+	for _, mf := range families {
+		byName[mf.GetName()] = mf
+	}
+
+	for _, s := range sensors {
+		mf, ok := byName[s.metric]
+		if !ok || len(mf.GetMetric()) == 0 {
+			continue
+		}
+
+		topic := p.opts.TopicPrefix + "/" + s.id
+
+		if err := p.discover("sensor", s.id, map[string]any{
+			"name":                s.name,
+			"state_topic":         topic,
+			"unit_of_measurement": s.unit,
+			"device_class":        s.deviceClass,
+			"state_class":         "measurement",
+		}); err != nil {
+			return err
+		}
+
+		value := strconv.FormatFloat(metrics.Value(mf.GetMetric()[0]), 'f', -1, 64)
+		if err := p.client.Publish(topic, []byte(value), true); err != nil {
+			return err
+		}
+	}
+
+	if mf, ok := byName[deviceActiveMetric]; ok {
+		for _, m := range mf.GetMetric() {
+			if err := p.publishDevice(m); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// publishDevice publishes the presence of a device as a device_tracker.
+func (p *Publisher) publishDevice(m *dto.Metric) error {
+	labels := make(map[string]string)
+	for _, lp := range m.GetLabel() {
+		labels[lp.GetName()] = lp.GetValue()
+	}
+
+	// Skip devices aggregated by the Devices collector.
+	mac := labels["mac"]
+	if !strings.Contains(mac, ":") {
+		return nil
+	}
+
+	id := "device_" + strings.ToLower(strings.ReplaceAll(mac, ":", ""))
+	topic := p.opts.TopicPrefix + "/" + id
+
+	if err := p.discover("device_tracker", id, map[string]any{
+		"name":             labels["name"],
+		"state_topic":      topic,
+		"payload_home":     "home",
+		"payload_not_home": "not_home",
+		"source_type":      "router",
+	}); err != nil {
+		return err
+	}
+
+	state := "not_home"
+	if metrics.Value(m) > 0 {
+		state = "home"
+	}
+
+	return p.client.Publish(topic, []byte(state), true)
+}
+
+// discover publishes the Home Assistant discovery payload of an entity, once
+// per connection.
+func (p *Publisher) discover(component, id string, config map[string]any) error {
+	if p.opts.DiscoveryPrefix == "" || p.discovered[id] {
+		return nil
+	}
+
+	config["unique_id"] = "livebox_exporter_" + id
+	config["device"] = map[string]any{
+		"identifiers":  []string{"livebox_exporter"},
+		"name":         "Livebox",
+		"manufacturer": "Orange",
+	}
+
+	payload, err := json.Marshal(config)
+	if err != nil {
+		return fmt.Errorf("failed to encode discovery payload: %w", err)
+	}
+
+	topic := fmt.Sprintf("%s/%s/livebox_exporter/%s/config", p.opts.DiscoveryPrefix, component, id)
+	if err := p.client.Publish(topic, payload, true); err != nil {
+		return err
+	}
+
+	p.discovered[id] = true
+
+	return nil
+}
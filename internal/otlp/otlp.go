@@ -0,0 +1,396 @@
+// Package otlp pushes the metrics of a Prometheus registry to an OpenTelemetry
+// collector using OTLP/gRPC or OTLP/HTTP.
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Tomy2e/livebox-exporter/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const scopeName = "github.com/Tomy2e/livebox-exporter"
+
+// exportTimeout is the timeout of the export requests, the default timeout of
+// the OpenTelemetry exporters.
+const exportTimeout = 10 * time.Second
+
+// Protocols supported by the Exporter, named as in the
+// OTEL_EXPORTER_OTLP_PROTOCOL environment variable.
+const (
+	ProtocolGRPC         = "grpc"
+	ProtocolHTTPProtobuf = "http/protobuf"
+	ProtocolHTTPJSON     = "http/json"
+)
+
+// Options of the Exporter.
+type Options struct {
+	// Endpoint is the URL of the OTLP/HTTP metrics endpoint, for example
+	// http://localhost:4318/v1/metrics, or the address of the OTLP/gRPC
+	// receiver, for example localhost:4317. TLS is used for a gRPC endpoint
+	// with the https scheme.
+	Endpoint string
+	// Protocol is one of ProtocolGRPC, ProtocolHTTPProtobuf or
+	// ProtocolHTTPJSON, ProtocolHTTPJSON is used if empty.
+	Protocol string
+	// Headers are added to each request.
+	Headers map[string]string
+	// Interval between each push.
+	Interval time.Duration
+	// ResourceAttributes identify the Livebox.
+	ResourceAttributes map[string]string
+	// HTTPClient used to send OTLP/HTTP requests, defaults to a client with a
+	// 10 seconds timeout.
+	HTTPClient *http.Client
+}
+
+// Exporter periodically pushes the metrics gathered from a Prometheus registry
+// to an OTLP endpoint.
+type Exporter struct {
+	gatherer  prometheus.Gatherer
+	opts      Options
+	startTime time.Time
+	timeout   time.Duration
+	conn      *grpc.ClientConn
+	client    colmetricspb.MetricsServiceClient
+}
+
+// NewExporter returns a new Exporter. The gRPC connection is established
+// lazily, on the first push.
+func NewExporter(gatherer prometheus.Gatherer, opts Options) (*Exporter, error) {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: exportTimeout}
+	}
+
+	if opts.Protocol == "" {
+		opts.Protocol = ProtocolHTTPJSON
+	}
+
+	e := &Exporter{
+		gatherer:  gatherer,
+		opts:      opts,
+		startTime: time.Now(),
+		timeout:   exportTimeout,
+	}
+
+	switch opts.Protocol {
+	case ProtocolHTTPJSON, ProtocolHTTPProtobuf:
+	case ProtocolGRPC:
+		target, creds, err := grpcTarget(opts.Endpoint)
+		if err != nil {
+			return nil, err
+		}
+
+		conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gRPC client: %w", err)
+		}
+
+		e.conn = conn
+		e.client = colmetricspb.NewMetricsServiceClient(conn)
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol %q (supported protocols: %s, %s, %s)",
+			opts.Protocol, ProtocolGRPC, ProtocolHTTPProtobuf, ProtocolHTTPJSON)
+	}
+
+	return e, nil
+}
+
+// grpcTarget returns the gRPC target and transport credentials of an endpoint,
+// which is either an address or a URL.
+func grpcTarget(endpoint string) (string, credentials.TransportCredentials, error) {
+	if !strings.Contains(endpoint, "://") {
+		return endpoint, insecure.NewCredentials(), nil
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid OTLP endpoint: %w", err)
+	}
+
+	switch u.Scheme {
+	case "http":
+		return u.Host, insecure.NewCredentials(), nil
+	case "https":
+		return u.Host, credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12}), nil
+	default:
+		return "", nil, fmt.Errorf("invalid OTLP endpoint: unsupported scheme %q", u.Scheme)
+	}
+}
+
+// Close closes the gRPC connection, if any.
+func (e *Exporter) Close() error {
+	if e.conn == nil {
+		return nil
+	}
+
+	return e.conn.Close()
+}
+
+// Run pushes metrics immediately, then every interval until the context is
+// canceled.
+func (e *Exporter) Run(ctx context.Context) {
+	metrics.Every(ctx, e.opts.Interval, "otlp", e.Push)
+}
+
+// Push gathers and pushes metrics once.
+func (e *Exporter) Push(ctx context.Context) error {
+	families := metrics.Gather(e.gatherer, "otlp")
+
+	req := e.convert(families, time.Now())
+
+	if e.client != nil {
+		return e.pushGRPC(ctx, req)
+	}
+
+	return e.pushHTTP(ctx, req)
+}
+
+func (e *Exporter) pushGRPC(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) error {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	if len(e.opts.Headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(e.opts.Headers))
+	}
+
+	resp, err := e.client.Export(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics: %w", err)
+	}
+
+	if rejected := resp.GetPartialSuccess().GetRejectedDataPoints(); rejected > 0 {
+		return fmt.Errorf("%d data points were rejected: %s", rejected, resp.GetPartialSuccess().GetErrorMessage())
+	}
+
+	return nil
+}
+
+func (e *Exporter) pushHTTP(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) error {
+	var (
+		body        []byte
+		contentType string
+		err         error
+	)
+
+	if e.opts.Protocol == ProtocolHTTPProtobuf {
+		body, err = proto.Marshal(req)
+		contentType = "application/x-protobuf"
+	} else {
+		// OTLP/JSON requires enum values to be encoded as numbers.
+		body, err = protojson.MarshalOptions{UseEnumNumbers: true}.Marshal(req)
+		contentType = "application/json"
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to encode metrics: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, e.opts.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	httpReq.Header.Set("Content-Type", contentType)
+
+	for key, value := range e.opts.Headers {
+		httpReq.Header.Set(key, value)
+	}
+
+	resp, err := e.opts.HTTPClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics: %w", err)
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to push metrics: unexpected status: %s", resp.Status)
+	}
+
+	return nil
+}
+
+func (e *Exporter) convert(families []*dto.MetricFamily, now time.Time) *colmetricspb.ExportMetricsServiceRequest {
+	var (
+		startTime = uint64(e.startTime.UnixNano())
+		timestamp = uint64(now.UnixNano())
+		metrics   = make([]*metricspb.Metric, 0, len(families))
+	)
+
+	for _, mf := range families {
+		m := &metricspb.Metric{
+			Name:        mf.GetName(),
+			Description: mf.GetHelp(),
+		}
+
+		switch mf.GetType() {
+		case dto.MetricType_COUNTER:
+			var dataPoints []*metricspb.NumberDataPoint
+
+			for _, pm := range mf.GetMetric() {
+				if v := pm.GetCounter().GetValue(); isFinite(v) {
+					dataPoints = append(dataPoints, &metricspb.NumberDataPoint{
+						Attributes:        labelsToAttributes(pm.GetLabel()),
+						StartTimeUnixNano: startTime,
+						TimeUnixNano:      timestamp,
+						Value:             &metricspb.NumberDataPoint_AsDouble{AsDouble: v},
+					})
+				}
+			}
+
+			m.Data = &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+				DataPoints:             dataPoints,
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				IsMonotonic:            true,
+			}}
+		case dto.MetricType_HISTOGRAM:
+			var dataPoints []*metricspb.HistogramDataPoint
+
+			for _, pm := range mf.GetMetric() {
+				dataPoints = append(dataPoints, convertHistogram(pm.GetHistogram(), labelsToAttributes(pm.GetLabel()), startTime, timestamp))
+			}
+
+			m.Data = &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+				DataPoints:             dataPoints,
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			}}
+		case dto.MetricType_SUMMARY:
+			var dataPoints []*metricspb.SummaryDataPoint
+
+			for _, pm := range mf.GetMetric() {
+				dataPoints = append(dataPoints, convertSummary(pm.GetSummary(), labelsToAttributes(pm.GetLabel()), startTime, timestamp))
+			}
+
+			m.Data = &metricspb.Metric_Summary{Summary: &metricspb.Summary{DataPoints: dataPoints}}
+		default:
+			var dataPoints []*metricspb.NumberDataPoint
+
+			for _, pm := range mf.GetMetric() {
+				v := pm.GetGauge().GetValue()
+				if pm.GetUntyped() != nil {
+					v = pm.GetUntyped().GetValue()
+				}
+
+				if isFinite(v) {
+					dataPoints = append(dataPoints, &metricspb.NumberDataPoint{
+						Attributes:   labelsToAttributes(pm.GetLabel()),
+						TimeUnixNano: timestamp,
+						Value:        &metricspb.NumberDataPoint_AsDouble{AsDouble: v},
+					})
+				}
+			}
+
+			m.Data = &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: dataPoints}}
+		}
+
+		metrics = append(metrics, m)
+	}
+
+	resourceAttributes := make([]*commonpb.KeyValue, 0, len(e.opts.ResourceAttributes))
+	for key, value := range e.opts.ResourceAttributes {
+		resourceAttributes = append(resourceAttributes, stringKeyValue(key, value))
+	}
+
+	return &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: &resourcepb.Resource{Attributes: resourceAttributes},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Scope:   &commonpb.InstrumentationScope{Name: scopeName},
+				Metrics: metrics,
+			}},
+		}},
+	}
+}
+
+func convertHistogram(h *dto.Histogram, attributes []*commonpb.KeyValue, startTime, timestamp uint64) *metricspb.HistogramDataPoint {
+	sum := h.GetSampleSum()
+	dp := &metricspb.HistogramDataPoint{
+		Attributes:        attributes,
+		StartTimeUnixNano: startTime,
+		TimeUnixNano:      timestamp,
+		Count:             h.GetSampleCount(),
+		Sum:               &sum,
+	}
+
+	// Prometheus buckets are cumulative, OTLP buckets are not. OTLP has an
+	// additional bucket for values above the last bound.
+	var previous uint64
+	for _, b := range h.GetBucket() {
+		if math.IsInf(b.GetUpperBound(), 1) {
+			continue
+		}
+
+		dp.ExplicitBounds = append(dp.ExplicitBounds, b.GetUpperBound())
+		dp.BucketCounts = append(dp.BucketCounts, b.GetCumulativeCount()-previous)
+		previous = b.GetCumulativeCount()
+	}
+
+	dp.BucketCounts = append(dp.BucketCounts, h.GetSampleCount()-previous)
+
+	return dp
+}
+
+func convertSummary(s *dto.Summary, attributes []*commonpb.KeyValue, startTime, timestamp uint64) *metricspb.SummaryDataPoint {
+	dp := &metricspb.SummaryDataPoint{
+		Attributes:        attributes,
+		StartTimeUnixNano: startTime,
+		TimeUnixNano:      timestamp,
+		Count:             s.GetSampleCount(),
+		Sum:               s.GetSampleSum(),
+	}
+
+	for _, q := range s.GetQuantile() {
+		if isFinite(q.GetValue()) {
+			dp.QuantileValues = append(dp.QuantileValues, &metricspb.SummaryDataPoint_ValueAtQuantile{
+				Quantile: q.GetQuantile(),
+				Value:    q.GetValue(),
+			})
+		}
+	}
+
+	return dp
+}
+
+func labelsToAttributes(labels []*dto.LabelPair) []*commonpb.KeyValue {
+	attributes := make([]*commonpb.KeyValue, 0, len(labels))
+	for _, lp := range labels {
+		attributes = append(attributes, stringKeyValue(lp.GetName(), lp.GetValue()))
+	}
+
+	return attributes
+}
+
+func stringKeyValue(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   key,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}},
+	}
+}
+
+// isFinite returns false for NaN and infinite values, they cannot be encoded
+// in JSON.
+func isFinite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
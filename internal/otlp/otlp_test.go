@@ -0,0 +1,286 @@
+package otlp
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func testRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "test_bytes_total",
+		Help: "Test counter.",
+	}, []string{"interface"})
+	counter.WithLabelValues("eth0").Add(42)
+
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "test_temperature",
+		Help: "Test gauge.",
+	})
+	gauge.Set(21.5)
+
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "test_duration_seconds",
+		Help:    "Test histogram.",
+		Buckets: []float64{1, 5},
+	})
+	for _, v := range []float64{0.5, 3, 3, 10} {
+		histogram.Observe(v)
+	}
+
+	registry.MustRegister(counter, gauge, histogram)
+
+	return registry
+}
+
+// checkRequest checks the conversion of the metrics of testRegistry.
+func checkRequest(t *testing.T, req *colmetricspb.ExportMetricsServiceRequest) {
+	t.Helper()
+
+	rm := req.GetResourceMetrics()
+	if len(rm) != 1 || len(rm[0].GetScopeMetrics()) != 1 {
+		t.Fatalf("unexpected request: %v", req)
+	}
+
+	if attrs := rm[0].GetResource().GetAttributes(); len(attrs) != 1 ||
+		attrs[0].GetKey() != "livebox.serial" || attrs[0].GetValue().GetStringValue() != "serial" {
+		t.Errorf("resource attributes = %v", attrs)
+	}
+
+	metrics := make(map[string]*metricspb.Metric)
+	for _, m := range rm[0].GetScopeMetrics()[0].GetMetrics() {
+		metrics[m.GetName()] = m
+	}
+
+	sum := metrics["test_bytes_total"].GetSum()
+	if sum == nil || !sum.GetIsMonotonic() ||
+		sum.GetAggregationTemporality() != metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE {
+		t.Fatalf("test_bytes_total is not a cumulative monotonic sum: %v", metrics["test_bytes_total"])
+	}
+
+	if dp := sum.GetDataPoints(); len(dp) != 1 || dp[0].GetAsDouble() != 42 ||
+		dp[0].GetAttributes()[0].GetValue().GetStringValue() != "eth0" || dp[0].GetStartTimeUnixNano() == 0 {
+		t.Errorf("test_bytes_total data points = %v", dp)
+	}
+
+	gauge := metrics["test_temperature"].GetGauge()
+	if gauge == nil || len(gauge.GetDataPoints()) != 1 || gauge.GetDataPoints()[0].GetAsDouble() != 21.5 {
+		t.Errorf("test_temperature = %v", metrics["test_temperature"])
+	}
+
+	histogram := metrics["test_duration_seconds"].GetHistogram()
+	if histogram == nil || len(histogram.GetDataPoints()) != 1 {
+		t.Fatalf("test_duration_seconds = %v", metrics["test_duration_seconds"])
+	}
+
+	// Cumulative Prometheus buckets are converted to per-bucket counts.
+	dp := histogram.GetDataPoints()[0]
+	if !slices.Equal(dp.GetExplicitBounds(), []float64{1, 5}) {
+		t.Errorf("explicit bounds = %v, want [1 5]", dp.GetExplicitBounds())
+	}
+
+	if !slices.Equal(dp.GetBucketCounts(), []uint64{1, 2, 1}) {
+		t.Errorf("bucket counts = %v, want [1 2 1]", dp.GetBucketCounts())
+	}
+
+	if dp.GetCount() != 4 || dp.GetSum() != 16.5 {
+		t.Errorf("count = %d, sum = %f, want 4 and 16.5", dp.GetCount(), dp.GetSum())
+	}
+}
+
+func TestExporterHTTP(t *testing.T) {
+	for _, protocol := range []string{ProtocolHTTPJSON, ProtocolHTTPProtobuf} {
+		t.Run(protocol, func(t *testing.T) {
+			requests := make(chan *colmetricspb.ExportMetricsServiceRequest, 1)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				if r.Header.Get("Authorization") != "Bearer token" {
+					t.Errorf("Authorization header = %q", r.Header.Get("Authorization"))
+				}
+
+				req := &colmetricspb.ExportMetricsServiceRequest{}
+				if protocol == ProtocolHTTPJSON {
+					err = protojson.Unmarshal(body, req)
+				} else {
+					err = proto.Unmarshal(body, req)
+				}
+
+				if err != nil {
+					t.Errorf("failed to decode request: %s", err)
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				requests <- req
+			}))
+			defer server.Close()
+
+			exporter, err := NewExporter(testRegistry(), Options{
+				Endpoint:           server.URL,
+				Protocol:           protocol,
+				Headers:            map[string]string{"Authorization": "Bearer token"},
+				Interval:           time.Hour,
+				ResourceAttributes: map[string]string{"livebox.serial": "serial"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			// Metrics are pushed immediately, without waiting for the
+			// interval.
+			go exporter.Run(ctx)
+
+			select {
+			case req := <-requests:
+				checkRequest(t, req)
+			case <-time.After(5 * time.Second):
+				t.Fatal("metrics were not pushed")
+			}
+		})
+	}
+}
+
+type metricsServer struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+	requests chan *colmetricspb.ExportMetricsServiceRequest
+	headers  chan metadata.MD
+}
+
+func (s *metricsServer) Export(ctx context.Context, req *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.headers <- md
+	s.requests <- req
+
+	return &colmetricspb.ExportMetricsServiceResponse{}, nil
+}
+
+func TestExporterGRPC(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := &metricsServer{
+		requests: make(chan *colmetricspb.ExportMetricsServiceRequest, 1),
+		headers:  make(chan metadata.MD, 1),
+	}
+
+	server := grpc.NewServer()
+	colmetricspb.RegisterMetricsServiceServer(server, srv)
+
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+
+	exporter, err := NewExporter(testRegistry(), Options{
+		Endpoint:           "http://" + lis.Addr().String(),
+		Protocol:           ProtocolGRPC,
+		Headers:            map[string]string{"Authorization": "Bearer token"},
+		Interval:           time.Hour,
+		ResourceAttributes: map[string]string{"livebox.serial": "serial"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer exporter.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := exporter.Push(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if md := <-srv.headers; !slices.Equal(md.Get("authorization"), []string{"Bearer token"}) {
+		t.Errorf("authorization metadata = %v", md.Get("authorization"))
+	}
+
+	checkRequest(t, <-srv.requests)
+}
+
+func TestNewExporterInvalidProtocol(t *testing.T) {
+	if _, err := NewExporter(prometheus.NewRegistry(), Options{Protocol: "udp"}); err == nil {
+		t.Error("NewExporter() succeeded with an unsupported protocol")
+	}
+}
+
+// stuckServer never responds to export requests.
+type stuckServer struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+}
+
+func (stuckServer) Export(ctx context.Context, _ *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestExporterTimeout(t *testing.T) {
+	exporter, err := NewExporter(testRegistry(), Options{Endpoint: "http://localhost", Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if timeout := exporter.opts.HTTPClient.Timeout; timeout != exportTimeout {
+		t.Errorf("HTTP client timeout = %s, want %s", timeout, exportTimeout)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := grpc.NewServer()
+	colmetricspb.RegisterMetricsServiceServer(server, stuckServer{})
+
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+
+	exporter, err = NewExporter(testRegistry(), Options{
+		Endpoint: "http://" + lis.Addr().String(),
+		Protocol: ProtocolGRPC,
+		Interval: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer exporter.Close()
+
+	exporter.timeout = 10 * time.Millisecond
+
+	done := make(chan error, 1)
+	go func() { done <- exporter.Push(context.Background()) }()
+
+	select {
+	case err := <-done:
+		if status.Code(err) != codes.DeadlineExceeded {
+			t.Errorf("Push() = %v, want a deadline exceeded error", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the gRPC export request did not time out")
+	}
+}
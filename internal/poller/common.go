@@ -1,9 +1,149 @@
 package poller
 
-import "math"
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	exporterLivebox "github.com/Tomy2e/livebox-exporter/pkg/livebox"
+
+	"github.com/Tomy2e/livebox-exporter/pkg/bitrate"
+	"github.com/prometheus/client_golang/prometheus"
+)
 
 const maxMbits = 2150
 
 func sanitizeMbits(mbits float64) float64 {
 	return math.Min(mbits, maxMbits)
 }
+
+// uptimeSetter is implemented by the types of the bitrate package that need
+// the Livebox uptime to tell counter resets from counter wraps.
+type uptimeSetter interface {
+	SetUptime(uptime time.Duration)
+}
+
+// setUptime gets the current Livebox uptime and saves it in all setters.
+// Errors are ignored as the uptime only improves reset detection.
+func setUptime(ctx context.Context, client exporterLivebox.API, setters ...uptimeSetter) {
+	uptime, err := exporterLivebox.GetUptime(ctx, client)
+	if err != nil {
+		return
+	}
+
+	for _, setter := range setters {
+		setter.SetUptime(uptime)
+	}
+}
+
+// metricVec is implemented by the Prometheus metric vectors.
+type metricVec interface {
+	Delete(labels prometheus.Labels) bool
+}
+
+// interfaceSeries keeps track of the interfaces whose series were updated, so
+// that the series of the interfaces that are no longer updated are removed
+// from the metric vectors.
+type interfaceSeries struct {
+	vecs []metricVec
+
+	mu      sync.Mutex
+	updated map[string]time.Time
+}
+
+func newInterfaceSeries(vecs ...metricVec) *interfaceSeries {
+	return &interfaceSeries{
+		vecs:    vecs,
+		updated: make(map[string]time.Time),
+	}
+}
+
+// touch records that the series of an interface were updated.
+func (is *interfaceSeries) touch(itf string) {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	is.updated[itf] = time.Now()
+}
+
+// expire removes the series of the interfaces that were not updated within
+// the TTL.
+func (is *interfaceSeries) expire(ttl time.Duration) {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	deadline := time.Now().Add(-ttl)
+
+	for itf, updated := range is.updated {
+		if updated.After(deadline) {
+			continue
+		}
+
+		for _, vec := range is.vecs {
+			vec.Delete(prometheus.Labels{"interface": itf})
+		}
+
+		delete(is.updated, itf)
+	}
+}
+
+// interfaceCounters exports the raw traffic counters of the Livebox interfaces
+// as Prometheus counters. All pollers export the same metrics, the source
+// label tells them apart.
+type interfaceCounters struct {
+	accumulator          *bitrate.Accumulator
+	txBytes, rxBytes     *prometheus.CounterVec
+	txPackets, rxPackets *prometheus.CounterVec
+	errors               *prometheus.CounterVec
+}
+
+func newInterfaceCounters(source string) *interfaceCounters {
+	newCounterVec := func(name, help string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name:        "livebox_interface" + name,
+			Help:        help,
+			ConstLabels: prometheus.Labels{"source": source},
+		}, []string{
+			// Name of the interface.
+			"interface",
+		})
+	}
+
+	return &interfaceCounters{
+		accumulator: bitrate.NewAccumulator(),
+		txBytes:     newCounterVec("_tx_bytes_total", "Transmitted bytes."),
+		rxBytes:     newCounterVec("_rx_bytes_total", "Received bytes."),
+		txPackets:   newCounterVec("_tx_packets_total", "Transmitted packets."),
+		rxPackets:   newCounterVec("_rx_packets_total", "Received packets."),
+		errors:      newCounterVec("_poll_errors_total", "Number of failed polls of the interface."),
+	}
+}
+
+func (ic *interfaceCounters) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		ic.txBytes,
+		ic.rxBytes,
+		ic.txPackets,
+		ic.rxPackets,
+		ic.errors,
+	}
+}
+
+// failed records a failed poll of an interface.
+func (ic *interfaceCounters) failed(itf string) {
+	ic.errors.With(prometheus.Labels{"interface": itf}).Inc()
+}
+
+// add adds the increase of the bytes and packets counters of an interface.
+func (ic *interfaceCounters) add(itf string, bytes, packets *bitrate.Counters) {
+	labels := prometheus.Labels{"interface": itf}
+
+	increases := ic.accumulator.Increases(itf+"/bytes", bytes)
+	ic.txBytes.With(labels).Add(float64(increases.Tx))
+	ic.rxBytes.With(labels).Add(float64(increases.Rx))
+
+	increases = ic.accumulator.Increases(itf+"/packets", packets)
+	ic.txPackets.With(labels).Add(float64(increases.Tx))
+	ic.rxPackets.With(labels).Add(float64(increases.Rx))
+}
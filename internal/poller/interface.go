@@ -3,10 +3,11 @@ package poller
 import (
 	"context"
 	"fmt"
+	"time"
 
-	"github.com/Tomy2e/livebox-api-client"
 	"github.com/Tomy2e/livebox-api-client/api/request"
 	"github.com/Tomy2e/livebox-exporter/pkg/bitrate"
+	exporterLivebox "github.com/Tomy2e/livebox-exporter/pkg/livebox"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -15,13 +16,14 @@ var _ Poller = &InterfaceMbits{}
 // InterfaceMbits allows to poll the current bandwidth usage on the Livebox
 // interfaces.
 type InterfaceMbits struct {
-	client           *livebox.Client
+	client           exporterLivebox.API
 	txMbits, rxMbits *prometheus.GaugeVec
+	series           *interfaceSeries
 }
 
 // NewInterfaceMbits returns a new InterfaceMbits poller.
-func NewInterfaceMbits(client *livebox.Client) *InterfaceMbits {
-	return &InterfaceMbits{
+func NewInterfaceMbits(client exporterLivebox.API) *InterfaceMbits {
+	im := &InterfaceMbits{
 		client: client,
 		txMbits: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Name: "livebox_interface_tx_mbits",
@@ -38,6 +40,10 @@ func NewInterfaceMbits(client *livebox.Client) *InterfaceMbits {
 			"interface",
 		}),
 	}
+
+	im.series = newInterfaceSeries(im.txMbits, im.rxMbits)
+
+	return im
 }
 
 // Collectors returns all metrics.
@@ -45,6 +51,18 @@ func (im *InterfaceMbits) Collectors() []prometheus.Collector {
 	return []prometheus.Collector{im.txMbits, im.rxMbits}
 }
 
+// Interval returns the preferred and minimum intervals between each poll. The
+// Livebox computes the rates every 30 seconds.
+func (im *InterfaceMbits) Interval() (preferred, minimum time.Duration) {
+	return 30 * time.Second, 5 * time.Second
+}
+
+// Expire removes the series of the interfaces that were not updated within the
+// TTL.
+func (im *InterfaceMbits) Expire(ttl time.Duration) {
+	im.series.expire(ttl)
+}
+
 // Poll polls the current bandwidth usage.
 func (im *InterfaceMbits) Poll(ctx context.Context) error {
 	var counters struct {
@@ -88,6 +106,8 @@ func (im *InterfaceMbits) Poll(ctx context.Context) error {
 		im.txMbits.
 			With(prometheus.Labels{"interface": iface}).
 			Set(bitrate.BitsPer30SecsToMbits(txCounter))
+
+		im.series.touch(iface)
 	}
 
 	return nil
@@ -2,10 +2,10 @@ package poller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
-	"github.com/Tomy2e/livebox-api-client"
 	"github.com/Tomy2e/livebox-api-client/api/request"
 	"github.com/Tomy2e/livebox-exporter/pkg/bitrate"
 	exporterLivebox "github.com/Tomy2e/livebox-exporter/pkg/livebox"
@@ -22,18 +22,20 @@ var _ Poller = &InterfaceHomeLanMbits{}
 // InterfaceHomeLanMbits is an experimental poller to get the current bandwidth
 // usage on the Livebox interfaces.
 type InterfaceHomeLanMbits struct {
-	client           *livebox.Client
+	client           exporterLivebox.API
 	interfaces       []*exporterLivebox.Interface
 	bitrate          *bitrate.Bitrate
 	txMbits, rxMbits *prometheus.GaugeVec
+	counters         *interfaceCounters
+	series           *interfaceSeries
 }
 
 // NewInterfaceHomeLanMbits returns a new InterfaceMbits poller.
-func NewInterfaceHomeLanMbits(client *livebox.Client, interfaces []*exporterLivebox.Interface) *InterfaceHomeLanMbits {
-	return &InterfaceHomeLanMbits{
+func NewInterfaceHomeLanMbits(client exporterLivebox.API, interfaces []*exporterLivebox.Interface) *InterfaceHomeLanMbits {
+	im := &InterfaceHomeLanMbits{
 		client:     client,
 		interfaces: interfaces,
-		bitrate:    bitrate.New(InterfaceHomeLanMbitsMinDelay),
+		bitrate:    bitrate.New(),
 		txMbits: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Name: "livebox_interface_homelan_tx_mbits",
 			Help: "Transmitted Mbits per second.",
@@ -48,48 +50,96 @@ func NewInterfaceHomeLanMbits(client *livebox.Client, interfaces []*exporterLive
 			// Name of the interface.
 			"interface",
 		}),
+		counters: newInterfaceCounters("homelan"),
 	}
+
+	im.series = newInterfaceSeries(
+		im.txMbits,
+		im.rxMbits,
+		im.counters.txBytes,
+		im.counters.rxBytes,
+		im.counters.txPackets,
+		im.counters.rxPackets,
+	)
+
+	return im
 }
 
 // Collectors returns all metrics.
 func (im *InterfaceHomeLanMbits) Collectors() []prometheus.Collector {
-	return []prometheus.Collector{
+	return append([]prometheus.Collector{
 		im.txMbits,
 		im.rxMbits,
+	}, im.counters.collectors()...)
+}
+
+// Interval returns the preferred and minimum intervals between each poll.
+func (im *InterfaceHomeLanMbits) Interval() (preferred, minimum time.Duration) {
+	return InterfaceHomeLanMbitsMinDelay, InterfaceHomeLanMbitsMinDelay
+}
+
+// Expire removes the series of the interfaces that were not updated within the
+// TTL.
+func (im *InterfaceHomeLanMbits) Expire(ttl time.Duration) {
+	im.series.expire(ttl)
+}
+
+// getHomeLanStats returns the byte and packet counters of an interface from the
+// HomeLan statistics. The counters of LAN interfaces are swapped, so that Rx is
+// the traffic received by the devices.
+func getHomeLanStats(ctx context.Context, client exporterLivebox.API, itf *exporterLivebox.Interface) (bytes, packets *bitrate.Counters, err error) {
+	var stats struct {
+		Status struct {
+			BytesReceived   uint64 `json:"BytesReceived"`
+			BytesSent       uint64 `json:"BytesSent"`
+			PacketsReceived uint64 `json:"PacketsReceived"`
+			PacketsSent     uint64 `json:"PacketsSent"`
+		} `json:"status"`
+	}
+
+	if err := client.Request(ctx, request.New(
+		fmt.Sprintf("HomeLan.Interface.%s.Stats", itf.Name),
+		"get",
+		nil,
+	), &stats); err != nil {
+		return nil, nil, fmt.Errorf("failed to get stats for interface: %s: %w", itf.Name, err)
+	}
+
+	bytes = &bitrate.Counters{
+		Tx: stats.Status.BytesSent,
+		Rx: stats.Status.BytesReceived,
 	}
+
+	packets = &bitrate.Counters{
+		Tx: stats.Status.PacketsSent,
+		Rx: stats.Status.PacketsReceived,
+	}
+
+	if !itf.IsWAN() {
+		bytes.Swap()
+		packets.Swap()
+	}
+
+	return bytes, packets, nil
 }
 
-// Poll polls the current bandwidth usage.
+// Poll polls the current bandwidth usage. Interfaces that fail do not prevent
+// the other interfaces from being polled.
 func (im *InterfaceHomeLanMbits) Poll(ctx context.Context) error {
-	for _, itf := range im.interfaces {
-		// Enforce InterfaceHomeLanMbitsMinDelay.
-		if !im.bitrate.ShouldMeasure(itf.Name) {
-			continue
-		}
-
-		var stats struct {
-			Status struct {
-				BytesReceived uint64 `json:"BytesReceived"`
-				BytesSent     uint64 `json:"BytesSent"`
-			} `json:"status"`
-		}
+	setUptime(ctx, im.client, im.bitrate, im.counters.accumulator)
 
-		if err := im.client.Request(ctx, request.New(
-			fmt.Sprintf("HomeLan.Interface.%s.Stats", itf.Name),
-			"get",
-			nil,
-		), &stats); err != nil {
-			return fmt.Errorf("failed to get stats for interface: %s: %w", itf.Name, err)
-		}
+	var errs []error
 
-		counters := &bitrate.Counters{
-			Tx: stats.Status.BytesSent,
-			Rx: stats.Status.BytesReceived,
+	for _, itf := range im.interfaces {
+		counters, packets, err := getHomeLanStats(ctx, im.client, itf)
+		if err != nil {
+			im.counters.failed(itf.Name)
+			errs = append(errs, err)
+			continue
 		}
 
-		if !itf.IsWAN() {
-			counters.Swap()
-		}
+		im.counters.add(itf.Name, counters, packets)
+		im.series.touch(itf.Name)
 
 		bitrates := im.bitrate.Measure(itf.Name, counters)
 
@@ -106,5 +156,5 @@ func (im *InterfaceHomeLanMbits) Poll(ctx context.Context) error {
 		}
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
@@ -2,9 +2,10 @@ package poller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
-	"github.com/Tomy2e/livebox-api-client"
 	"github.com/Tomy2e/livebox-api-client/api/request"
 	"github.com/Tomy2e/livebox-exporter/pkg/bitrate"
 	exporterLivebox "github.com/Tomy2e/livebox-exporter/pkg/livebox"
@@ -16,18 +17,20 @@ var _ Poller = &InterfaceNetDevMbits{}
 // InterfaceNetDevMbits is an experimental poller to get the current bandwidth
 // usage on the Livebox interfaces.
 type InterfaceNetDevMbits struct {
-	client           *livebox.Client
+	client           exporterLivebox.API
 	interfaces       []*exporterLivebox.Interface
 	bitrate          *bitrate.Bitrate
 	txMbits, rxMbits *prometheus.GaugeVec
+	counters         *interfaceCounters
+	series           *interfaceSeries
 }
 
 // NewInterfaceNetDevMbits returns a new InterfaceNetDevMbits poller.
-func NewInterfaceNetDevMbits(client *livebox.Client, interfaces []*exporterLivebox.Interface) *InterfaceNetDevMbits {
-	return &InterfaceNetDevMbits{
+func NewInterfaceNetDevMbits(client exporterLivebox.API, interfaces []*exporterLivebox.Interface) *InterfaceNetDevMbits {
+	im := &InterfaceNetDevMbits{
 		client:     client,
 		interfaces: interfaces,
-		bitrate:    bitrate.New(0),
+		bitrate:    bitrate.New(),
 		txMbits: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Name: "livebox_interface_netdev_tx_mbits",
 			Help: "Transmitted Mbits per second.",
@@ -42,22 +45,36 @@ func NewInterfaceNetDevMbits(client *livebox.Client, interfaces []*exporterLiveb
 			// Name of the interface.
 			"interface",
 		}),
+		counters: newInterfaceCounters("netdev"),
 	}
+
+	im.series = newInterfaceSeries(
+		im.txMbits,
+		im.rxMbits,
+		im.counters.txBytes,
+		im.counters.rxBytes,
+		im.counters.txPackets,
+		im.counters.rxPackets,
+	)
+
+	return im
 }
 
 // Collectors returns all metrics.
 func (im *InterfaceNetDevMbits) Collectors() []prometheus.Collector {
-	return []prometheus.Collector{
+	return append([]prometheus.Collector{
 		im.txMbits,
 		im.rxMbits,
-	}
+	}, im.counters.collectors()...)
 }
 
-func (im *InterfaceNetDevMbits) getNetDevStats(ctx context.Context, interfaceName string) (uint64, uint64, error) {
+func (im *InterfaceNetDevMbits) getNetDevStats(ctx context.Context, interfaceName string) (bytes, packets *bitrate.Counters, err error) {
 	var stats struct {
 		Status struct {
-			RxBytes uint64
-			TxBytes uint64
+			RxBytes   uint64
+			TxBytes   uint64
+			RxPackets uint64
+			TxPackets uint64
 		} `json:"status"`
 	}
 
@@ -66,17 +83,21 @@ func (im *InterfaceNetDevMbits) getNetDevStats(ctx context.Context, interfaceNam
 		"getNetDevStats",
 		nil,
 	), &stats); err != nil {
-		return 0, 0, err
+		return nil, nil, err
 	}
 
-	return stats.Status.RxBytes, stats.Status.TxBytes, nil
+	return &bitrate.Counters{Rx: stats.Status.RxBytes, Tx: stats.Status.TxBytes},
+		&bitrate.Counters{Rx: stats.Status.RxPackets, Tx: stats.Status.TxPackets},
+		nil
 }
 
-func (im *InterfaceNetDevMbits) getSSIDStats(ctx context.Context, interfaceName string) (uint64, uint64, error) {
+func (im *InterfaceNetDevMbits) getSSIDStats(ctx context.Context, interfaceName string) (bytes, packets *bitrate.Counters, err error) {
 	var stats struct {
 		Status struct {
-			BytesReceived uint64
-			BytesSent     uint64
+			BytesReceived   uint64
+			BytesSent       uint64
+			PacketsReceived uint64
+			PacketsSent     uint64
 		} `json:"status"`
 	}
 
@@ -85,33 +106,57 @@ func (im *InterfaceNetDevMbits) getSSIDStats(ctx context.Context, interfaceName
 		"getSSIDStats",
 		nil,
 	), &stats); err != nil {
-		return 0, 0, err
+		return nil, nil, err
 	}
 
-	return stats.Status.BytesReceived, stats.Status.BytesSent, nil
+	return &bitrate.Counters{Rx: stats.Status.BytesReceived, Tx: stats.Status.BytesSent},
+		&bitrate.Counters{Rx: stats.Status.PacketsReceived, Tx: stats.Status.PacketsSent},
+		nil
+}
+
+// Interval returns the preferred and minimum intervals between each poll.
+func (im *InterfaceNetDevMbits) Interval() (preferred, minimum time.Duration) {
+	return 5 * time.Second, time.Second
+}
+
+// Expire removes the series of the interfaces that were not updated within the
+// TTL.
+func (im *InterfaceNetDevMbits) Expire(ttl time.Duration) {
+	im.series.expire(ttl)
 }
 
-// Poll polls the current bandwidth usage.
+// Poll polls the current bandwidth usage. Interfaces that fail do not prevent
+// the other interfaces from being polled.
 func (im *InterfaceNetDevMbits) Poll(ctx context.Context) error {
+	setUptime(ctx, im.client, im.bitrate, im.counters.accumulator)
+
+	var errs []error
+
 	for _, itf := range im.interfaces {
 		var (
-			counters = &bitrate.Counters{}
-			err      error
+			counters, packets *bitrate.Counters
+			err               error
 		)
 
 		if itf.IsWLAN() {
-			counters.Rx, counters.Tx, err = im.getSSIDStats(ctx, itf.Name)
+			counters, packets, err = im.getSSIDStats(ctx, itf.Name)
 		} else {
-			counters.Rx, counters.Tx, err = im.getNetDevStats(ctx, itf.Name)
+			counters, packets, err = im.getNetDevStats(ctx, itf.Name)
 		}
 		if err != nil {
-			return fmt.Errorf("failed to get stats for interface (WLAN=%t): %s: %w", itf.IsWLAN(), itf.Name, err)
+			im.counters.failed(itf.Name)
+			errs = append(errs, fmt.Errorf("failed to get stats for interface (WLAN=%t): %s: %w", itf.IsWLAN(), itf.Name, err))
+			continue
 		}
 
 		if !itf.IsWAN() {
 			counters.Swap()
+			packets.Swap()
 		}
 
+		im.counters.add(itf.Name, counters, packets)
+		im.series.touch(itf.Name)
+
 		bitrates := im.bitrate.Measure(itf.Name, counters)
 
 		if bitrates.Rx != nil && !bitrates.Rx.Reset {
@@ -127,5 +172,5 @@ func (im *InterfaceNetDevMbits) Poll(ctx context.Context) error {
 		}
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
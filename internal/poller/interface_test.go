@@ -0,0 +1,121 @@
+package poller
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tomy2e/livebox-exporter/internal/liveboxsim"
+	exporterLivebox "github.com/Tomy2e/livebox-exporter/pkg/livebox"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TestPollInterfaceErrors checks that the per-interface pollers keep polling
+// the other interfaces when an interface fails, and count the failure.
+func TestPollInterfaceErrors(t *testing.T) {
+	for name, tc := range map[string]struct {
+		poller  func(exporterLivebox.API, []*exporterLivebox.Interface) (Poller, *interfaceCounters)
+		service string
+		method  string
+	}{
+		"homelan": {
+			poller: func(client exporterLivebox.API, interfaces []*exporterLivebox.Interface) (Poller, *interfaceCounters) {
+				p := NewInterfaceHomeLanMbits(client, interfaces)
+				return p, p.counters
+			},
+			service: "HomeLan.Interface.eth1.Stats",
+			method:  "get",
+		},
+		"netdev": {
+			poller: func(client exporterLivebox.API, interfaces []*exporterLivebox.Interface) (Poller, *interfaceCounters) {
+				p := NewInterfaceNetDevMbits(client, interfaces)
+				return p, p.counters
+			},
+			service: "NeMo.Intf.eth1",
+			method:  "getNetDevStats",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			sim := liveboxsim.New(liveboxsim.DefaultScenario())
+
+			server := httptest.NewServer(sim)
+			defer server.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			client, err := exporterLivebox.NewClient("admin", server.URL, server.Client())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			interfaces, err := exporterLivebox.DiscoverInterfaces(ctx, client)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			p, counters := tc.poller(client, interfaces)
+
+			if err := sim.Apply(&liveboxsim.Step{
+				Action:  liveboxsim.ActionFail,
+				Service: tc.service,
+				Method:  tc.method,
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			err = p.Poll(ctx)
+			if err == nil || !strings.Contains(err.Error(), "eth1") {
+				t.Fatalf("Poll() error = %v, want an error for eth1", err)
+			}
+
+			if got := counterValues(t, counters.errors); len(got) != 1 || got["eth1"] != 1 {
+				t.Errorf("poll errors = %v, want 1 for eth1", got)
+			}
+
+			// The other interfaces were polled.
+			got := counterValues(t, counters.rxBytes)
+			if _, ok := got["eth1"]; ok || len(got) != len(interfaces)-1 {
+				t.Errorf("rx bytes = %v, want the interfaces other than eth1", got)
+			}
+
+			// The next poll succeeds, the error counter is kept.
+			if err := p.Poll(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := counterValues(t, counters.errors); got["eth1"] != 1 {
+				t.Errorf("poll errors after recovery = %v, want 1 for eth1", got)
+			}
+		})
+	}
+}
+
+// counterValues returns the values of a counter vector by interface.
+func counterValues(t *testing.T, vec *prometheus.CounterVec) map[string]float64 {
+	t.Helper()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(vec)
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := make(map[string]float64)
+
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() == "interface" {
+					values[label.GetValue()] = m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,102 @@
+package poller
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Tomy2e/livebox-exporter/pkg/reflect"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Status is the result of the last polls of a poller.
+type Status struct {
+	Name          string    `json:"name"`
+	LastSuccess   time.Time `json:"last_success"`
+	LastError     string    `json:"last_error,omitempty"`
+	LastErrorTime time.Time `json:"last_error_time"`
+}
+
+// Monitor records the result of the polls of pollers.
+type Monitor struct {
+	mu       sync.Mutex
+	statuses []*Status
+}
+
+// NewMonitor returns a new Monitor.
+func NewMonitor() *Monitor {
+	return &Monitor{}
+}
+
+// Wrap returns the pollers wrapped so that the result of their polls is
+// recorded by the monitor.
+func (m *Monitor) Wrap(pollers Pollers) Pollers {
+	wrapped := make(Pollers, 0, len(pollers))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, poller := range pollers {
+		status := &Status{Name: Name(poller)}
+		m.statuses = append(m.statuses, status)
+		wrapped = append(wrapped, &monitored{Poller: poller, monitor: m, status: status})
+	}
+
+	return wrapped
+}
+
+// Statuses returns the status of all monitored pollers.
+func (m *Monitor) Statuses() []Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	statuses := make([]Status, 0, len(m.statuses))
+	for _, status := range m.statuses {
+		statuses = append(statuses, *status)
+	}
+
+	return statuses
+}
+
+// monitored is a poller monitored by a Monitor.
+type monitored struct {
+	Poller
+	monitor *Monitor
+	status  *Status
+}
+
+// Name returns the name of the wrapped poller.
+func (mp *monitored) Name() string {
+	return mp.status.Name
+}
+
+// Poll polls the wrapped poller and records the result.
+func (mp *monitored) Poll(ctx context.Context) error {
+	err := mp.Poller.Poll(ctx)
+
+	mp.monitor.mu.Lock()
+	defer mp.monitor.mu.Unlock()
+
+	if err != nil {
+		mp.status.LastError = err.Error()
+		mp.status.LastErrorTime = time.Now()
+	} else {
+		mp.status.LastSuccess = time.Now()
+	}
+
+	return err
+}
+
+// Collectors returns the collectors of the wrapped poller.
+func (mp *monitored) Collectors() []prometheus.Collector {
+	return mp.Poller.Collectors()
+}
+
+// Name returns the name of a poller.
+func Name(poller Poller) string {
+	if named, ok := poller.(interface{ Name() string }); ok {
+		return named.Name()
+	}
+
+	return reflect.GetType(poller)
+}
@@ -2,11 +2,9 @@ package poller
 
 import (
 	"context"
-	"fmt"
+	"time"
 
-	"github.com/Tomy2e/livebox-exporter/pkg/reflect"
 	"github.com/prometheus/client_golang/prometheus"
-	"golang.org/x/sync/errgroup"
 )
 
 // Poller is an interface that allows polling a system and updating Prometheus
@@ -14,6 +12,11 @@ import (
 type Poller interface {
 	Poll(ctx context.Context) error
 	Collectors() []prometheus.Collector
+	// Interval returns the preferred and minimum intervals between each
+	// poll.
+	Interval() (preferred, minimum time.Duration)
+	// Expire removes the series that were not updated within the TTL.
+	Expire(ttl time.Duration)
 }
 
 // Pollers is a list of pollers.
@@ -27,21 +30,3 @@ func (p Pollers) Collectors() (c []prometheus.Collector) {
 
 	return
 }
-
-// Poll runs all pollers in parallel.
-func (p Pollers) Poll(ctx context.Context) error {
-	eg, ctx := errgroup.WithContext(ctx)
-
-	for _, poller := range p {
-		poller := poller
-		eg.Go(func() error {
-			if err := poller.Poll(ctx); err != nil {
-				return fmt.Errorf("%s: %w", reflect.GetType(poller), err)
-			}
-
-			return nil
-		})
-	}
-
-	return eg.Wait()
-}
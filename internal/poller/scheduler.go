@@ -0,0 +1,91 @@
+package poller
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Scheduler runs each poller independently at its own interval.
+type Scheduler struct {
+	pollers        Pollers
+	intervals      []time.Duration
+	staleIntervals int
+}
+
+// NewScheduler returns a new Scheduler for the pollers. The interval of a
+// poller is the override for its name if there is one, otherwise its preferred
+// interval. Intervals shorter than the minimum interval of a poller are
+// increased to the minimum interval. Series that were not updated for
+// staleIntervals intervals are removed.
+func NewScheduler(pollers Pollers, overrides map[string]time.Duration, staleIntervals int) *Scheduler {
+	if staleIntervals < 1 {
+		staleIntervals = 1
+	}
+
+	s := &Scheduler{pollers: pollers, staleIntervals: staleIntervals}
+
+	for _, poller := range pollers {
+		name := Name(poller)
+		preferred, minimum := poller.Interval()
+
+		interval := preferred
+		if override, ok := overrides[name]; ok {
+			interval = override
+		}
+
+		if interval < minimum {
+			log.Printf("WARN: polling interval %s of %s is shorter than its minimum interval, using %s", interval, name, minimum)
+			interval = minimum
+		}
+
+		s.intervals = append(s.intervals, interval)
+	}
+
+	return s
+}
+
+// Run polls each poller at its interval until the context is canceled. A poll
+// is canceled when it takes longer than the interval of its poller, so that a
+// hung poll does not block the following ones. Errors are passed to onError,
+// they do not stop the other pollers.
+func (s *Scheduler) Run(ctx context.Context, onError func(name string, err error)) {
+	var wg sync.WaitGroup
+
+	for i, poller := range s.pollers {
+		name := Name(poller)
+		interval := s.intervals[i]
+		ttl := time.Duration(s.staleIntervals) * interval
+
+		log.Printf("INFO: polling %s every %s", name, interval)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for {
+				pollCtx, cancel := context.WithTimeout(ctx, interval)
+				err := poller.Poll(pollCtx)
+				cancel()
+
+				if err != nil && ctx.Err() == nil {
+					onError(name, err)
+				}
+
+				poller.Expire(ttl)
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+}
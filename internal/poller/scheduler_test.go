@@ -0,0 +1,170 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fakePoller is a poller that signals its polls and expirations.
+type fakePoller struct {
+	name               string
+	err                error
+	hang               bool
+	preferred, minimum time.Duration
+	polled, expired    chan struct{}
+}
+
+func newFakePoller(name string, err error) *fakePoller {
+	return &fakePoller{
+		name:      name,
+		err:       err,
+		preferred: time.Hour,
+		polled:    make(chan struct{}, 1),
+		expired:   make(chan struct{}, 1),
+	}
+}
+
+func (fp *fakePoller) Name() string { return fp.name }
+
+func (fp *fakePoller) Poll(ctx context.Context) error {
+	select {
+	case fp.polled <- struct{}{}:
+	default:
+	}
+
+	if fp.hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	return fp.err
+}
+
+func (fp *fakePoller) Collectors() []prometheus.Collector { return nil }
+
+func (fp *fakePoller) Interval() (preferred, minimum time.Duration) {
+	return fp.preferred, fp.minimum
+}
+
+func (fp *fakePoller) Expire(time.Duration) {
+	select {
+	case fp.expired <- struct{}{}:
+	default:
+	}
+}
+
+func TestNewScheduler(t *testing.T) {
+	short := newFakePoller("short", nil)
+	short.minimum = time.Minute
+
+	s := NewScheduler(Pollers{
+		newFakePoller("preferred", nil),
+		newFakePoller("override", nil),
+		short,
+	}, map[string]time.Duration{
+		"override": 10 * time.Second,
+		"short":    time.Second,
+	}, 3)
+
+	want := []time.Duration{time.Hour, 10 * time.Second, time.Minute}
+	for i, interval := range s.intervals {
+		if interval != want[i] {
+			t.Errorf("interval of poller %d = %s, want %s", i, interval, want[i])
+		}
+	}
+}
+
+// TestSchedulerErrorIsolation checks that the errors of a poller are passed to
+// the callback and do not prevent the other pollers from being polled.
+func TestSchedulerErrorIsolation(t *testing.T) {
+	failing := newFakePoller("failing", errors.New("failed"))
+	working := newFakePoller("working", nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		mu     sync.Mutex
+		errs   = make(map[string]error)
+		done   = make(chan struct{})
+		failed = make(chan struct{}, 1)
+	)
+
+	go func() {
+		defer close(done)
+
+		NewScheduler(Pollers{failing, working}, nil, 1).Run(ctx, func(name string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			errs[name] = err
+
+			select {
+			case failed <- struct{}{}:
+			default:
+			}
+		})
+	}()
+
+	for _, ch := range []chan struct{}{failing.polled, failing.expired, working.polled, working.expired, failed} {
+		select {
+		case <-ch:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the pollers were not polled")
+		}
+	}
+
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(errs) != 1 || errs["failing"] == nil {
+		t.Errorf("errors = %v, want an error for the failing poller only", errs)
+	}
+}
+
+// TestSchedulerPollTimeout checks that a hung poll is canceled after the
+// interval of its poller and that the poller is polled again.
+func TestSchedulerPollTimeout(t *testing.T) {
+	hung := newFakePoller("hung", nil)
+	hung.hang = true
+	hung.preferred = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errs := make(chan error, 10)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		NewScheduler(Pollers{hung}, nil, 1).Run(ctx, func(_ string, err error) {
+			select {
+			case errs <- err:
+			default:
+			}
+		})
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("error = %v, want %v", err, context.DeadlineExceeded)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("the hung poll was not canceled")
+		}
+	}
+
+	cancel()
+	<-done
+}
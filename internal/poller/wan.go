@@ -2,28 +2,48 @@ package poller
 
 import (
 	"context"
+	"time"
 
-	"github.com/Tomy2e/livebox-api-client"
 	"github.com/Tomy2e/livebox-api-client/api/request"
 	"github.com/Tomy2e/livebox-exporter/pkg/bitrate"
+	exporterLivebox "github.com/Tomy2e/livebox-exporter/pkg/livebox"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 var _ Poller = &WANMbits{}
 
+// wanInterfaceName is the interface label of the WAN counters when no WAN
+// interface was discovered.
+const wanInterfaceName = "wan"
+
 // WANMbits is an experimental poller to get the current bandwidth usage on the
 // WAN interface of the Livebox.
 type WANMbits struct {
-	client           *livebox.Client
+	client           exporterLivebox.API
 	bitrate          *bitrate.Bitrate
 	txMbits, rxMbits prometheus.Gauge
+	// name is the interface label of the counters.
+	name     string
+	counters *interfaceCounters
 }
 
-// NewWANMbits returns a new WANMbits poller.
-func NewWANMbits(client *livebox.Client) *WANMbits {
+// NewWANMbits returns a new WANMbits poller. The counters are labeled with the
+// name of the discovered WAN interface.
+func NewWANMbits(client exporterLivebox.API, interfaces []*exporterLivebox.Interface) *WANMbits {
+	name := wanInterfaceName
+
+	for _, itf := range interfaces {
+		if itf.IsWAN() {
+			name = itf.Name
+			break
+		}
+	}
+
 	return &WANMbits{
-		client:  client,
-		bitrate: bitrate.New(InterfaceHomeLanMbitsMinDelay),
+		client:   client,
+		bitrate:  bitrate.New(),
+		name:     name,
+		counters: newInterfaceCounters("wan"),
 		txMbits: prometheus.NewGauge(prometheus.GaugeOpts{
 			Name: "livebox_wan_tx_mbits",
 			Help: "Transmitted Mbits per second on the WAN interface.",
@@ -37,26 +57,39 @@ func NewWANMbits(client *livebox.Client) *WANMbits {
 
 // Collectors returns all metrics.
 func (im *WANMbits) Collectors() []prometheus.Collector {
-	return []prometheus.Collector{
+	return append([]prometheus.Collector{
 		im.txMbits,
 		im.rxMbits,
-	}
+	}, im.counters.collectors()...)
+}
+
+// Interval returns the preferred and minimum intervals between each poll.
+func (im *WANMbits) Interval() (preferred, minimum time.Duration) {
+	return 30 * time.Second, 5 * time.Second
 }
 
+// Expire does nothing as the WAN metrics are always for the same interface.
+func (im *WANMbits) Expire(_ time.Duration) {}
+
 // Poll polls the current bandwidth usage on the WAN interface.
 func (im *WANMbits) Poll(ctx context.Context) error {
 	var stats struct {
 		Status struct {
-			BytesReceived uint64 `json:"BytesReceived"`
-			BytesSent     uint64 `json:"BytesSent"`
+			BytesReceived   uint64 `json:"BytesReceived"`
+			BytesSent       uint64 `json:"BytesSent"`
+			PacketsReceived uint64 `json:"PacketsReceived"`
+			PacketsSent     uint64 `json:"PacketsSent"`
 		} `json:"status"`
 	}
 
+	setUptime(ctx, im.client, im.bitrate, im.counters.accumulator)
+
 	if err := im.client.Request(
 		ctx,
 		request.New("HomeLan", "getWANCounters", nil),
 		&stats,
 	); err != nil {
+		im.counters.failed(im.name)
 		return err
 	}
 
@@ -65,7 +98,15 @@ func (im *WANMbits) Poll(ctx context.Context) error {
 		Rx: stats.Status.BytesReceived,
 	}
 
+	packets := &bitrate.Counters{
+		Tx: stats.Status.PacketsSent,
+		Rx: stats.Status.PacketsReceived,
+	}
+
 	counters.Swap()
+	packets.Swap()
+
+	im.counters.add(im.name, counters, packets)
 
 	bitrates := im.bitrate.Measure("WAN", counters)
 
@@ -0,0 +1,128 @@
+package remotewrite
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// label is a label of a time series.
+type label struct {
+	name, value string
+}
+
+// encodeWriteRequest encodes metric families to a remote-write WriteRequest
+// protobuf message:
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label { string name = 1; string value = 2; }
+//	message Sample { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(families []*dto.MetricFamily, timestamp int64) []byte {
+	var b []byte
+
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			labels := make([]label, 0, len(m.GetLabel())+2)
+			for _, lp := range m.GetLabel() {
+				// A label with an empty value is the same as no label.
+				if lp.GetValue() != "" {
+					labels = append(labels, label{lp.GetName(), lp.GetValue()})
+				}
+			}
+
+			ts := timestamp
+			if m.TimestampMs != nil {
+				ts = m.GetTimestampMs()
+			}
+
+			add := func(name string, value float64, extra ...label) {
+				b = protowire.AppendTag(b, 1, protowire.BytesType)
+				b = protowire.AppendBytes(b, encodeTimeSeries(name, labels, extra, value, ts))
+			}
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add(mf.GetName(), m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add(mf.GetName(), m.GetGauge().GetValue())
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				hasInf := false
+
+				for _, bucket := range h.GetBucket() {
+					hasInf = hasInf || math.IsInf(bucket.GetUpperBound(), 1)
+					add(mf.GetName()+"_bucket", float64(bucket.GetCumulativeCount()), label{"le", formatFloat(bucket.GetUpperBound())})
+				}
+
+				if !hasInf {
+					add(mf.GetName()+"_bucket", float64(h.GetSampleCount()), label{"le", "+Inf"})
+				}
+
+				add(mf.GetName()+"_sum", h.GetSampleSum())
+				add(mf.GetName()+"_count", float64(h.GetSampleCount()))
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+
+				for _, q := range s.GetQuantile() {
+					add(mf.GetName(), q.GetValue(), label{"quantile", formatFloat(q.GetQuantile())})
+				}
+
+				add(mf.GetName()+"_sum", s.GetSampleSum())
+				add(mf.GetName()+"_count", float64(s.GetSampleCount()))
+			default:
+				add(mf.GetName(), m.GetUntyped().GetValue())
+			}
+		}
+	}
+
+	return b
+}
+
+func encodeTimeSeries(name string, labels, extra []label, value float64, timestamp int64) []byte {
+	all := make([]label, 0, len(labels)+len(extra)+1)
+	all = append(all, label{"__name__", name})
+	all = append(all, labels...)
+	all = append(all, extra...)
+
+	// Labels must be sorted by name.
+	sort.Slice(all, func(i, j int) bool { return all[i].name < all[j].name })
+
+	var b []byte
+
+	for _, l := range all {
+		var lb []byte
+		lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+		lb = protowire.AppendString(lb, l.name)
+		lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+		lb = protowire.AppendString(lb, l.value)
+
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, lb)
+	}
+
+	var sb []byte
+	sb = protowire.AppendTag(sb, 1, protowire.Fixed64Type)
+	sb = protowire.AppendFixed64(sb, math.Float64bits(value))
+	sb = protowire.AppendTag(sb, 2, protowire.VarintType)
+	sb = protowire.AppendVarint(sb, uint64(timestamp))
+
+	b = protowire.AppendTag(b, 2, protowire.BytesType)
+	b = protowire.AppendBytes(b, sb)
+
+	return b
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
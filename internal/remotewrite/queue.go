@@ -0,0 +1,178 @@
+package remotewrite
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// queue stores the requests waiting to be sent, oldest first.
+type queue interface {
+	// push adds a request to the queue, dropping the oldest requests if the
+	// queue is full.
+	push(payload []byte) error
+	// peek returns the oldest request.
+	peek() ([]byte, bool, error)
+	// pop removes the oldest request.
+	pop() error
+}
+
+// memoryQueue is a queue stored in memory.
+type memoryQueue struct {
+	payloads [][]byte
+	size     int
+	maxSize  int
+}
+
+func newMemoryQueue(maxSize int) *memoryQueue {
+	return &memoryQueue{maxSize: maxSize}
+}
+
+func (q *memoryQueue) push(payload []byte) error {
+	q.payloads = append(q.payloads, payload)
+	q.size += len(payload)
+
+	for q.size > q.maxSize && len(q.payloads) > 1 {
+		_ = q.pop()
+	}
+
+	return nil
+}
+
+func (q *memoryQueue) peek() ([]byte, bool, error) {
+	if len(q.payloads) == 0 {
+		return nil, false, nil
+	}
+
+	return q.payloads[0], true, nil
+}
+
+func (q *memoryQueue) pop() error {
+	if len(q.payloads) > 0 {
+		q.size -= len(q.payloads[0])
+		q.payloads = q.payloads[1:]
+	}
+
+	return nil
+}
+
+// walQueue is a queue stored on disk, each request is stored in its own file
+// so that requests survive restarts of the exporter.
+type walQueue struct {
+	dir     string
+	maxSize int64
+	// segments are the files of the WAL, oldest first. The directory is
+	// listed on each push, to pick up changes made outside of the queue.
+	segments []walSegment
+}
+
+// walSegment is a file of the WAL.
+type walSegment struct {
+	name string
+	size int64
+}
+
+func newWALQueue(dir string, maxSize int64) (*walQueue, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create WAL directory: %w", err)
+	}
+
+	q := &walQueue{dir: dir, maxSize: maxSize}
+
+	if err := q.list(); err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// list lists the files of the WAL, oldest first.
+func (q *walQueue) list() error {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read WAL directory: %w", err)
+	}
+
+	q.segments = q.segments[:0]
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".snappy" {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		q.segments = append(q.segments, walSegment{name: entry.Name(), size: info.Size()})
+	}
+
+	// Names are zero-padded timestamps.
+	sort.Slice(q.segments, func(i, j int) bool { return q.segments[i].name < q.segments[j].name })
+
+	return nil
+}
+
+func (q *walQueue) push(payload []byte) error {
+	name := fmt.Sprintf("%020d.snappy", time.Now().UnixNano())
+
+	if err := os.WriteFile(filepath.Join(q.dir, name), payload, 0o644); err != nil {
+		return fmt.Errorf("failed to write WAL segment: %w", err)
+	}
+
+	if err := q.list(); err != nil {
+		return err
+	}
+
+	var size int64
+	for _, segment := range q.segments {
+		size += segment.size
+	}
+
+	for len(q.segments) > 1 && size > q.maxSize {
+		size -= q.segments[0].size
+
+		if err := q.pop(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (q *walQueue) peek() ([]byte, bool, error) {
+	for len(q.segments) > 0 {
+		payload, err := os.ReadFile(filepath.Join(q.dir, q.segments[0].name))
+		if errors.Is(err, os.ErrNotExist) {
+			// The segment was removed outside of the queue.
+			q.segments = q.segments[1:]
+			continue
+		}
+
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read WAL segment: %w", err)
+		}
+
+		return payload, true, nil
+	}
+
+	return nil, false, nil
+}
+
+func (q *walQueue) pop() error {
+	if len(q.segments) == 0 {
+		return nil
+	}
+
+	if err := os.Remove(filepath.Join(q.dir, q.segments[0].name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove WAL segment: %w", err)
+	}
+
+	q.segments = q.segments[1:]
+
+	return nil
+}
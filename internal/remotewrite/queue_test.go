@@ -0,0 +1,52 @@
+package remotewrite
+
+import (
+	"os"
+	"testing"
+)
+
+func TestWALQueue(t *testing.T) {
+	dir := t.TempDir()
+
+	q, err := newWALQueue(dir, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, payload := range []string{"first", "second", "third"} {
+		if err := q.push([]byte(payload)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The oldest request is dropped when the WAL exceeds its maximum size.
+	payload, ok, err := q.peek()
+	if err != nil || !ok || string(payload) != "second" {
+		t.Fatalf("peek() = %q, %t, %v, want second", payload, ok, err)
+	}
+
+	// Requests survive restarts of the exporter.
+	q, err = newWALQueue(dir, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"second", "third"} {
+		payload, ok, err := q.peek()
+		if err != nil || !ok || string(payload) != want {
+			t.Fatalf("peek() = %q, %t, %v, want %s", payload, ok, err, want)
+		}
+
+		if err := q.pop(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, ok, _ := q.peek(); ok {
+		t.Error("peek() returned a request from an empty queue")
+	}
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("%d segments left in the WAL directory", len(entries))
+	}
+}
@@ -0,0 +1,158 @@
+// Package remotewrite pushes the metrics of a Prometheus registry to a
+// Prometheus remote-write endpoint, for sites that cannot be scraped.
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Tomy2e/livebox-exporter/internal/metrics"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// memoryQueueMaxSize is the maximum size of the queue when no WAL directory is
+// configured.
+const memoryQueueMaxSize = 16 << 20
+
+// defaultTimeout is the timeout of the requests sent to the endpoint when no
+// HTTP client is specified.
+const defaultTimeout = 30 * time.Second
+
+// Options of the Pusher.
+type Options struct {
+	// URL of the remote-write endpoint.
+	URL string
+	// Username and Password for basic authentication.
+	Username, Password string
+	// BearerToken for bearer authentication.
+	BearerToken string
+	// Interval between each push.
+	Interval time.Duration
+	// WALDir is the directory where requests are stored until they are sent.
+	// Requests are stored in memory if empty.
+	WALDir string
+	// WALMaxSize is the maximum size of the WAL in bytes, the oldest requests
+	// are dropped when it is exceeded.
+	WALMaxSize int64
+	// HTTPClient used to send requests, defaults to a client with a 30 seconds
+	// timeout.
+	HTTPClient *http.Client
+}
+
+// Pusher periodically pushes the metrics gathered from a Prometheus registry
+// to a remote-write endpoint.
+type Pusher struct {
+	gatherer prometheus.Gatherer
+	opts     Options
+	queue    queue
+}
+
+// errNonRetryable is returned when the endpoint rejected a request.
+var errNonRetryable = errors.New("request rejected")
+
+// NewPusher returns a new Pusher.
+func NewPusher(gatherer prometheus.Gatherer, opts Options) (*Pusher, error) {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: defaultTimeout}
+	}
+
+	var q queue = newMemoryQueue(memoryQueueMaxSize)
+
+	if opts.WALDir != "" {
+		wal, err := newWALQueue(opts.WALDir, opts.WALMaxSize)
+		if err != nil {
+			return nil, err
+		}
+
+		q = wal
+	}
+
+	return &Pusher{
+		gatherer: gatherer,
+		opts:     opts,
+		queue:    q,
+	}, nil
+}
+
+// Run pushes metrics immediately, then every interval until the context is
+// canceled.
+func (p *Pusher) Run(ctx context.Context) {
+	metrics.Every(ctx, p.opts.Interval, "remote-write", p.Push)
+}
+
+// Push gathers metrics, queues them and sends all queued requests, oldest
+// first. Requests stay in the queue when the endpoint cannot be reached.
+func (p *Pusher) Push(ctx context.Context) error {
+	families := metrics.Gather(p.gatherer, "remote-write")
+
+	payload := snappy.Encode(nil, encodeWriteRequest(families, time.Now().UnixMilli()))
+
+	if err := p.queue.push(payload); err != nil {
+		return err
+	}
+
+	for {
+		payload, ok, err := p.queue.peek()
+		if err != nil || !ok {
+			return err
+		}
+
+		if err := p.send(ctx, payload); err != nil {
+			if !errors.Is(err, errNonRetryable) {
+				return err
+			}
+
+			log.Printf("WARN: remote-write: dropping request: %s", err)
+		}
+
+		if err := p.queue.pop(); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *Pusher) send(ctx context.Context, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.opts.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "livebox-exporter")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	switch {
+	case p.opts.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+p.opts.BearerToken)
+	case p.opts.Username != "":
+		req.SetBasicAuth(p.opts.Username, p.opts.Password)
+	}
+
+	resp, err := p.opts.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("unexpected status: %s: %s", resp.Status, body)
+
+	// Server errors and rate limiting are retried, other errors are not.
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		return fmt.Errorf("failed to push metrics: %w", err)
+	}
+
+	return fmt.Errorf("%w: %w", errNonRetryable, err)
+}
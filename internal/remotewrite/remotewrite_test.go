@@ -0,0 +1,104 @@
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestPusherRun(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "test_bytes_total",
+		Help: "Test counter.",
+	}, []string{"interface", "ssid"})
+	counter.WithLabelValues("eth0", "").Add(42)
+	registry.MustRegister(counter)
+
+	requests := make(chan []byte, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		payload, err := snappy.Decode(nil, body)
+		if err != nil {
+			t.Errorf("failed to decode request: %s", err)
+		}
+
+		requests <- payload
+	}))
+	defer server.Close()
+
+	pusher, err := NewPusher(registry, Options{URL: server.URL, Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Metrics are pushed immediately, without waiting for the interval.
+	go pusher.Run(ctx)
+
+	select {
+	case payload := <-requests:
+		if !bytes.Contains(payload, []byte("test_bytes_total")) || !bytes.Contains(payload, []byte("eth0")) {
+			t.Errorf("payload %q does not contain the counter", payload)
+		}
+
+		// Labels with an empty value are not sent.
+		if bytes.Contains(payload, []byte("ssid")) {
+			t.Errorf("payload %q contains the empty ssid label", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("metrics were not pushed")
+	}
+}
+
+// TestPusherTimeout checks that a request to a stuck endpoint times out and
+// stays in the queue.
+func TestPusherTimeout(t *testing.T) {
+	pusher, err := NewPusher(prometheus.NewRegistry(), Options{URL: "http://localhost", Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if timeout := pusher.opts.HTTPClient.Timeout; timeout != defaultTimeout {
+		t.Errorf("default timeout = %s, want %s", timeout, defaultTimeout)
+	}
+
+	stuck := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		<-stuck
+	}))
+	defer server.Close()
+	defer close(stuck)
+
+	pusher, err = NewPusher(prometheus.NewRegistry(), Options{
+		URL:        server.URL,
+		Interval:   time.Hour,
+		HTTPClient: &http.Client{Timeout: 10 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pusher.Push(context.Background()); err == nil {
+		t.Fatal("Push succeeded, want a timeout")
+	}
+
+	if _, ok, err := pusher.queue.peek(); err != nil || !ok {
+		t.Errorf("the request is not queued: %v", err)
+	}
+}
@@ -0,0 +1,361 @@
+// Package status serves a JSON snapshot of the latest known state of the
+// Livebox, built from the metrics gathered by the exporter.
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Tomy2e/livebox-exporter/internal/metrics"
+	exporterLivebox "github.com/Tomy2e/livebox-exporter/pkg/livebox"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Sources of the interface rates, by metric prefix.
+var interfaceRateSources = map[string]string{
+	"livebox_interface_":         "results",
+	"livebox_interface_homelan_": "homelan",
+	"livebox_interface_netdev_":  "netdev",
+}
+
+// Status is the latest known state of the Livebox.
+type Status struct {
+	Time          time.Time                   `json:"time"`
+	Livebox       *exporterLivebox.DeviceInfo `json:"livebox,omitempty"`
+	Session       exporterLivebox.SessionInfo `json:"session"`
+	UptimeSeconds *float64                    `json:"uptime_seconds,omitempty"`
+	Interfaces    []*Interface                `json:"interfaces"`
+	WAN           *Rates                      `json:"wan,omitempty"`
+	ONT           *ONT                        `json:"ont,omitempty"`
+	Devices       []*Device                   `json:"devices"`
+}
+
+// Rates are the current rates in Mbits per second.
+type Rates struct {
+	RxMbits *float64 `json:"rx_mbits,omitempty"`
+	TxMbits *float64 `json:"tx_mbits,omitempty"`
+}
+
+// Interface is a network interface discovered on the Livebox.
+type Interface struct {
+	Name  string `json:"name"`
+	Flags string `json:"flags"`
+	WAN   bool   `json:"wan"`
+	WLAN  bool   `json:"wlan"`
+	// Rates of the interface by source: "results" (HomeLan.getResults),
+	// "homelan" or "netdev" (experimental pollers).
+	Rates map[string]*Rates `json:"rates,omitempty"`
+}
+
+// ONT contains the readings of the GPON ONT.
+type ONT struct {
+	TemperatureCelsius         float64 `json:"temperature_celsius"`
+	DownstreamCurrentRateBytes float64 `json:"downstream_current_rate_bytes"`
+	UpstreamCurrentRateBytes   float64 `json:"upstream_current_rate_bytes"`
+}
+
+// Device is a device known by the Livebox.
+type Device struct {
+	MAC       string `json:"mac"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Active    bool   `json:"active"`
+	IPv4      string `json:"ipv4,omitempty"`
+	Interface string `json:"interface,omitempty"`
+	SSID      string `json:"ssid,omitempty"`
+	Layer     string `json:"layer,omitempty"`
+	Band      string `json:"band,omitempty"`
+	Vendor    string `json:"vendor,omitempty"`
+	Owner     string `json:"owner,omitempty"`
+	Room      string `json:"room,omitempty"`
+	Group     string `json:"group,omitempty"`
+	Rates     *Rates `json:"rates,omitempty"`
+	// RatesSource is the source of the rates of the device.
+	RatesSource string   `json:"rates_source,omitempty"`
+	RxBytes     *float64 `json:"rx_bytes,omitempty"`
+	TxBytes     *float64 `json:"tx_bytes,omitempty"`
+}
+
+// Handler serves the status of the Livebox as JSON.
+type Handler struct {
+	client     exporterLivebox.API
+	gatherer   prometheus.Gatherer
+	interfaces []*exporterLivebox.Interface
+
+	// retryInterval is the delay between two requests of the identity.
+	retryInterval time.Duration
+
+	mu      sync.Mutex
+	livebox *exporterLivebox.DeviceInfo
+}
+
+// NewHandler returns a new Handler. The status is built from the metrics
+// gathered from the gatherer, the identity of the Livebox is requested in the
+// background by Run using the client.
+func NewHandler(
+	client exporterLivebox.API,
+	gatherer prometheus.Gatherer,
+	interfaces []*exporterLivebox.Interface,
+) *Handler {
+	return &Handler{
+		client:        client,
+		gatherer:      gatherer,
+		interfaces:    interfaces,
+		retryInterval: 30 * time.Second,
+	}
+}
+
+// Run requests the identity of the Livebox until it succeeds once or the
+// context is canceled. The status has no identity until then.
+func (h *Handler) Run(ctx context.Context) {
+	for {
+		deviceInfo, err := exporterLivebox.GetDeviceInfo(ctx, h.client)
+		if err == nil {
+			h.mu.Lock()
+			h.livebox = deviceInfo
+			h.mu.Unlock()
+
+			return
+		}
+
+		log.Printf("WARN: status: %s", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(h.retryInterval):
+		}
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(h.Status(r.Context())); err != nil {
+		log.Printf("WARN: status: failed to write response: %s", err)
+	}
+}
+
+// Status returns the latest known state of the Livebox.
+func (h *Handler) Status(context.Context) *Status {
+	families := metrics.Gather(h.gatherer, "status")
+
+	byName := make(map[string]*dto.MetricFamily, len(families))
+	for _, mf := range families {
+		byName[mf.GetName()] = mf
+	}
+
+	status := &Status{
+		Time:       time.Now(),
+		Livebox:    h.identity(),
+		Session:    h.client.Session(),
+		Interfaces: h.buildInterfaces(byName),
+		Devices:    buildDevices(byName),
+	}
+
+	if v, ok := singleValue(byName, "livebox_deviceinfo_uptime_seconds_total"); ok {
+		status.UptimeSeconds = &v
+	}
+
+	if rates := buildRates(byName, "livebox_wan_", nil); rates != nil {
+		status.WAN = rates
+	}
+
+	if temperature, ok := singleValue(byName, "livebox_ont_temperature_celsius"); ok {
+		status.ONT = &ONT{TemperatureCelsius: temperature}
+		status.ONT.DownstreamCurrentRateBytes, _ = singleValue(byName, "livebox_ont_downstream_current_rate_bytes")
+		status.ONT.UpstreamCurrentRateBytes, _ = singleValue(byName, "livebox_ont_upstream_current_rate_bytes")
+	}
+
+	return status
+}
+
+// identity returns the identity of the Livebox, or nil if Run has not
+// received it yet.
+func (h *Handler) identity() *exporterLivebox.DeviceInfo {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.livebox
+}
+
+func (h *Handler) buildInterfaces(byName map[string]*dto.MetricFamily) []*Interface {
+	interfaces := make([]*Interface, 0, len(h.interfaces))
+
+	for _, itf := range h.interfaces {
+		i := &Interface{
+			Name:  itf.Name,
+			Flags: itf.Flags,
+			WAN:   itf.IsWAN(),
+			WLAN:  itf.IsWLAN(),
+		}
+
+		for prefix, source := range interfaceRateSources {
+			if rates := buildRates(byName, prefix, map[string]string{"interface": itf.Name}); rates != nil {
+				if i.Rates == nil {
+					i.Rates = make(map[string]*Rates)
+				}
+
+				i.Rates[source] = rates
+			}
+		}
+
+		interfaces = append(interfaces, i)
+	}
+
+	sort.Slice(interfaces, func(i, j int) bool {
+		return interfaces[i].Name < interfaces[j].Name
+	})
+
+	return interfaces
+}
+
+func buildDevices(byName map[string]*dto.MetricFamily) []*Device {
+	devices := make(map[string]*Device)
+
+	device := func(labels map[string]string) *Device {
+		dev, ok := devices[labels["mac"]]
+		if !ok {
+			dev = &Device{
+				MAC:  labels["mac"],
+				Name: labels["name"],
+				Type: labels["type"],
+			}
+			devices[dev.MAC] = dev
+		}
+
+		return dev
+	}
+
+	for _, m := range byName["livebox_device_info"].GetMetric() {
+		labels := labelMap(m)
+		dev := device(labels)
+		dev.IPv4 = labels["ipv4"]
+		dev.Interface = labels["interface"]
+		dev.SSID = labels["ssid"]
+		dev.Layer = labels["layer"]
+		dev.Band = labels["band"]
+		dev.Vendor = labels["vendor"]
+		dev.Owner = labels["owner"]
+		dev.Room = labels["room"]
+		dev.Group = labels["group"]
+	}
+
+	for _, m := range byName["livebox_device_active"].GetMetric() {
+		device(labelMap(m)).Active = metrics.Value(m) > 0
+	}
+
+	for _, direction := range []string{"rx", "tx"} {
+		for _, m := range byName["livebox_device_"+direction+"_mbits"].GetMetric() {
+			labels := labelMap(m)
+			dev := device(labels)
+			dev.RatesSource = labels["source"]
+
+			if dev.Rates == nil {
+				dev.Rates = &Rates{}
+			}
+
+			v := metrics.Value(m)
+			if direction == "rx" {
+				dev.Rates.RxMbits = &v
+			} else {
+				dev.Rates.TxMbits = &v
+			}
+		}
+
+		for _, m := range byName["livebox_device_"+direction+"_bytes_total"].GetMetric() {
+			dev := device(labelMap(m))
+
+			v := metrics.Value(m)
+			if direction == "rx" {
+				dev.RxBytes = &v
+			} else {
+				dev.TxBytes = &v
+			}
+		}
+	}
+
+	list := make([]*Device, 0, len(devices))
+	for _, dev := range devices {
+		list = append(list, dev)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Active != list[j].Active {
+			return list[i].Active
+		}
+
+		return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name)
+	})
+
+	return list
+}
+
+// buildRates returns the rates of the <prefix>rx_mbits and <prefix>tx_mbits
+// metrics matching the labels, or nil if none of them is found.
+func buildRates(byName map[string]*dto.MetricFamily, prefix string, labels map[string]string) *Rates {
+	var rates Rates
+
+	if m := findMetric(byName[prefix+"rx_mbits"], labels); m != nil {
+		v := metrics.Value(m)
+		rates.RxMbits = &v
+	}
+
+	if m := findMetric(byName[prefix+"tx_mbits"], labels); m != nil {
+		v := metrics.Value(m)
+		rates.TxMbits = &v
+	}
+
+	if rates.RxMbits == nil && rates.TxMbits == nil {
+		return nil
+	}
+
+	return &rates
+}
+
+// findMetric returns the first metric of a family that has all the labels.
+func findMetric(mf *dto.MetricFamily, labels map[string]string) *dto.Metric {
+	for _, m := range mf.GetMetric() {
+		metricLabels := labelMap(m)
+		matches := true
+
+		for name, value := range labels {
+			if metricLabels[name] != value {
+				matches = false
+				break
+			}
+		}
+
+		if matches {
+			return m
+		}
+	}
+
+	return nil
+}
+
+// singleValue returns the value of a metric without labels.
+func singleValue(byName map[string]*dto.MetricFamily, name string) (float64, bool) {
+	if m := findMetric(byName[name], nil); m != nil {
+		return metrics.Value(m), true
+	}
+
+	return 0, false
+}
+
+func labelMap(m *dto.Metric) map[string]string {
+	labels := make(map[string]string, len(m.GetLabel()))
+	for _, lp := range m.GetLabel() {
+		labels[lp.GetName()] = lp.GetValue()
+	}
+
+	return labels
+}
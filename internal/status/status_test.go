@@ -0,0 +1,208 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Tomy2e/livebox-api-client/api/request"
+	exporterLivebox "github.com/Tomy2e/livebox-exporter/pkg/livebox"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fakeAPI is a Livebox API whose requests are handled by a function.
+type fakeAPI struct {
+	request func(ctx context.Context, req *request.Request) (any, error)
+	session exporterLivebox.SessionInfo
+}
+
+func (fa *fakeAPI) Request(ctx context.Context, req *request.Request, out any) error {
+	response, err := fa.request(ctx, req)
+	if err != nil || out == nil {
+		return err
+	}
+
+	b, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, out)
+}
+
+func (fa *fakeAPI) Events(context.Context, []string) <-chan *exporterLivebox.EventMessage {
+	return nil
+}
+
+func (fa *fakeAPI) Session() exporterLivebox.SessionInfo {
+	return fa.session
+}
+
+func newTestRegistry(t *testing.T) *prometheus.Registry {
+	t.Helper()
+
+	registry := prometheus.NewRegistry()
+
+	uptime := prometheus.NewGauge(prometheus.GaugeOpts{Name: "livebox_deviceinfo_uptime_seconds_total"})
+	uptime.Set(3600)
+
+	wanRx := prometheus.NewGauge(prometheus.GaugeOpts{Name: "livebox_wan_rx_mbits"})
+	wanRx.Set(12.5)
+
+	interfaceTx := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "livebox_interface_tx_mbits"}, []string{"interface"})
+	interfaceTx.WithLabelValues("eth0").Set(3)
+
+	ontTemperature := prometheus.NewGauge(prometheus.GaugeOpts{Name: "livebox_ont_temperature_celsius"})
+	ontTemperature.Set(45)
+
+	deviceLabels := []string{"mac", "name", "type"}
+
+	deviceInfo := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "livebox_device_info"},
+		append(deviceLabels, "ipv4", "interface", "vendor"))
+	deviceInfo.WithLabelValues("00:1A:2B:00:00:01", "laptop", "Computer", "192.168.1.10", "eth4", "Acme").Set(1)
+	deviceInfo.WithLabelValues("00:1A:2B:00:00:02", "Phone", "Mobile", "192.168.1.11", "wl0", "").Set(1)
+
+	deviceActive := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "livebox_device_active"}, deviceLabels)
+	deviceActive.WithLabelValues("00:1A:2B:00:00:01", "laptop", "Computer").Set(0)
+	deviceActive.WithLabelValues("00:1A:2B:00:00:02", "Phone", "Mobile").Set(1)
+
+	deviceRx := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "livebox_device_rx_mbits"}, append(deviceLabels, "source"))
+	deviceRx.WithLabelValues("00:1A:2B:00:00:02", "Phone", "Mobile", "station").Set(1.5)
+
+	deviceTx := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "livebox_device_tx_bytes_total"}, deviceLabels)
+	deviceTx.WithLabelValues("00:1A:2B:00:00:02", "Phone", "Mobile").Add(2048)
+
+	registry.MustRegister(uptime, wanRx, interfaceTx, ontTemperature, deviceInfo, deviceActive, deviceRx, deviceTx)
+
+	return registry
+}
+
+func TestHandlerStatus(t *testing.T) {
+	api := &fakeAPI{
+		request: func(context.Context, *request.Request) (any, error) {
+			return map[string]any{"status": exporterLivebox.DeviceInfo{ModelName: "Livebox 5", SerialNumber: "SN1"}}, nil
+		},
+		session: exporterLivebox.SessionInfo{Address: "http://192.168.1.1", Logins: 2},
+	}
+
+	h := NewHandler(api, newTestRegistry(t), []*exporterLivebox.Interface{
+		{Name: "wl0", Flags: "wlanvap"},
+		{Name: "eth0", Flags: "eth wan"},
+	})
+
+	status := h.Status(context.Background())
+
+	if status.Livebox != nil {
+		t.Errorf("Livebox = %+v before Run, want nil", status.Livebox)
+	}
+
+	h.Run(context.Background())
+
+	status = h.Status(context.Background())
+
+	if status.Livebox == nil || status.Livebox.ModelName != "Livebox 5" || status.Livebox.SerialNumber != "SN1" {
+		t.Errorf("Livebox = %+v, want Livebox 5 SN1", status.Livebox)
+	}
+
+	if status.Session.Logins != 2 {
+		t.Errorf("Session.Logins = %d, want 2", status.Session.Logins)
+	}
+
+	if status.UptimeSeconds == nil || *status.UptimeSeconds != 3600 {
+		t.Errorf("UptimeSeconds = %v, want 3600", status.UptimeSeconds)
+	}
+
+	if status.WAN == nil || status.WAN.RxMbits == nil || *status.WAN.RxMbits != 12.5 || status.WAN.TxMbits != nil {
+		t.Errorf("WAN = %+v, want rx 12.5 and no tx", status.WAN)
+	}
+
+	if status.ONT == nil || status.ONT.TemperatureCelsius != 45 {
+		t.Errorf("ONT = %+v, want temperature 45", status.ONT)
+	}
+
+	if len(status.Interfaces) != 2 {
+		t.Fatalf("got %d interfaces, want 2", len(status.Interfaces))
+	}
+
+	eth0, wl0 := status.Interfaces[0], status.Interfaces[1]
+	if eth0.Name != "eth0" || !eth0.WAN || eth0.WLAN || wl0.Name != "wl0" || !wl0.WLAN {
+		t.Errorf("Interfaces = %+v %+v, want eth0 (WAN) then wl0 (WLAN)", eth0, wl0)
+	}
+
+	if rates := eth0.Rates["results"]; rates == nil || rates.TxMbits == nil || *rates.TxMbits != 3 {
+		t.Errorf("eth0 rates = %+v, want results tx 3", eth0.Rates)
+	}
+
+	if wl0.Rates != nil {
+		t.Errorf("wl0 rates = %+v, want nil", wl0.Rates)
+	}
+
+	if len(status.Devices) != 2 {
+		t.Fatalf("got %d devices, want 2", len(status.Devices))
+	}
+
+	// Active devices come first.
+	phone, laptop := status.Devices[0], status.Devices[1]
+
+	if phone.Name != "Phone" || !phone.Active || phone.Interface != "wl0" || phone.RatesSource != "station" {
+		t.Errorf("Devices[0] = %+v, want the active Phone on wl0", phone)
+	}
+
+	if phone.Rates == nil || phone.Rates.RxMbits == nil || *phone.Rates.RxMbits != 1.5 {
+		t.Errorf("phone rates = %+v, want rx 1.5", phone.Rates)
+	}
+
+	if phone.TxBytes == nil || *phone.TxBytes != 2048 || phone.RxBytes != nil {
+		t.Errorf("phone bytes = %v %v, want tx 2048 and no rx", phone.RxBytes, phone.TxBytes)
+	}
+
+	if laptop.Name != "laptop" || laptop.Active || laptop.IPv4 != "192.168.1.10" || laptop.Vendor != "Acme" {
+		t.Errorf("Devices[1] = %+v, want the inactive laptop", laptop)
+	}
+}
+
+func TestHandlerRunRetries(t *testing.T) {
+	requests := 0
+	api := &fakeAPI{
+		request: func(context.Context, *request.Request) (any, error) {
+			requests++
+			if requests < 3 {
+				return nil, errors.New("unavailable")
+			}
+
+			return map[string]any{"status": exporterLivebox.DeviceInfo{ModelName: "Livebox 6"}}, nil
+		},
+	}
+
+	h := NewHandler(api, prometheus.NewRegistry(), nil)
+	h.retryInterval = time.Millisecond
+	h.Run(context.Background())
+
+	if requests != 3 {
+		t.Errorf("got %d requests, want 3", requests)
+	}
+
+	if livebox := h.Status(context.Background()).Livebox; livebox == nil || livebox.ModelName != "Livebox 6" {
+		t.Errorf("Livebox = %+v, want Livebox 6", livebox)
+	}
+}
+
+func TestHandlerRunCanceled(t *testing.T) {
+	api := &fakeAPI{
+		request: func(context.Context, *request.Request) (any, error) {
+			return nil, errors.New("unavailable")
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	h := NewHandler(api, prometheus.NewRegistry(), nil)
+	h.Run(ctx)
+
+	if livebox := h.Status(context.Background()).Livebox; livebox != nil {
+		t.Errorf("Livebox = %+v, want nil", livebox)
+	}
+}
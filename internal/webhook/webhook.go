@@ -0,0 +1,273 @@
+// Package webhook forwards Livebox events to HTTP webhooks.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	exporterLivebox "github.com/Tomy2e/livebox-exporter/pkg/livebox"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SignatureHeader is the header containing the HMAC-SHA256 signature of the
+// request body, when a secret is configured.
+const SignatureHeader = "X-Livebox-Exporter-Signature"
+
+// queueSize is the maximum number of events waiting to be sent to a webhook.
+const queueSize = 1000
+
+// defaultTimeout is the timeout of the requests sent to webhooks when no HTTP
+// client is specified.
+const defaultTimeout = 10 * time.Second
+
+// Presets are shortcuts for the Livebox event channels.
+var Presets = map[string][]string{
+	"devices":  {"Devices.Device"},
+	"wan":      {"NMC", "NeMo.Intf.data"},
+	"wifi":     {"NMC.Wifi"},
+	"firmware": {"DeviceInfo"},
+	"voip":     {"VoiceService"},
+}
+
+// ExpandChannels returns the Livebox event channels of a list of presets or
+// channel names.
+func ExpandChannels(names []string) (channels []string) {
+	for _, name := range names {
+		if preset, ok := Presets[name]; ok {
+			channels = append(channels, preset...)
+		} else {
+			channels = append(channels, name)
+		}
+	}
+
+	return
+}
+
+// Event is a normalized Livebox event.
+type Event struct {
+	Time       time.Time `json:"time"`
+	Channel    string    `json:"channel"`
+	Handler    string    `json:"handler"`
+	Reason     string    `json:"reason"`
+	Attributes any       `json:"attributes"`
+}
+
+// Payload is the body of the requests sent to webhooks.
+type Payload struct {
+	Events []*Event `json:"events"`
+}
+
+// Options of the Forwarder.
+type Options struct {
+	// Channels are the Livebox event channels to subscribe to.
+	Channels []string
+	// URLs of the webhooks.
+	URLs []string
+	// Secret used to sign requests, requests are not signed if empty.
+	Secret string
+	// BatchSize is the maximum number of events sent in a single request.
+	BatchSize int
+	// BatchInterval is the maximum delay before a batch is sent.
+	BatchInterval time.Duration
+	// MaxRetries is the maximum number of retries of a failed request.
+	MaxRetries int
+	// HTTPClient used to send requests, defaults to a client with a 10 seconds
+	// timeout.
+	HTTPClient *http.Client
+}
+
+// Forwarder forwards Livebox events to webhooks.
+type Forwarder struct {
+	client   exporterLivebox.API
+	opts     Options
+	requests *prometheus.CounterVec
+	dropped  prometheus.Counter
+	// backoff is the delay before the first retry, it doubles after each
+	// retry.
+	backoff time.Duration
+}
+
+// NewForwarder returns a new Forwarder.
+func NewForwarder(client exporterLivebox.API, opts Options) *Forwarder {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: defaultTimeout}
+	}
+
+	return &Forwarder{
+		client:  client,
+		opts:    opts,
+		backoff: time.Second,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "livebox_webhook_requests_total",
+			Help: "Number of requests sent to webhooks.",
+		}, []string{
+			// Result of the request: success or failure.
+			"result",
+		}),
+		dropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "livebox_webhook_events_dropped_total",
+			Help: "Number of events that could not be sent to webhooks.",
+		}),
+	}
+}
+
+// Collectors returns all metrics.
+func (f *Forwarder) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{f.requests, f.dropped}
+}
+
+// Run subscribes to Livebox events and forwards them to webhooks until the
+// context is canceled.
+func (f *Forwarder) Run(ctx context.Context) {
+	queues := make([]chan *Event, len(f.opts.URLs))
+
+	for i, url := range f.opts.URLs {
+		queues[i] = make(chan *Event, queueSize)
+		go f.send(ctx, url, queues[i])
+	}
+
+	for evt := range f.client.Events(ctx, f.opts.Channels) {
+		if evt.Error != nil {
+			log.Printf("WARN: webhook: event error: %s", evt.Error)
+			continue
+		}
+
+		event := &Event{
+			Time:       time.Now(),
+			Channel:    f.channel(evt.Event.Handler),
+			Handler:    evt.Event.Handler,
+			Reason:     evt.Event.Reason,
+			Attributes: evt.Event.Attributes,
+		}
+
+		for _, queue := range queues {
+			select {
+			case queue <- event:
+			default:
+				f.dropped.Inc()
+			}
+		}
+	}
+}
+
+// channel returns the subscribed channel of an event handler.
+func (f *Forwarder) channel(handler string) string {
+	for _, channel := range f.opts.Channels {
+		if handler == channel || strings.HasPrefix(handler, channel+".") {
+			return channel
+		}
+	}
+
+	return ""
+}
+
+// send sends the events of the queue to a webhook in batches.
+func (f *Forwarder) send(ctx context.Context, url string, queue <-chan *Event) {
+	ticker := time.NewTicker(f.opts.BatchInterval)
+	defer ticker.Stop()
+
+	var batch []*Event
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		if err := f.post(ctx, url, batch); err != nil {
+			log.Printf("WARN: webhook: %s", err)
+			f.dropped.Add(float64(len(batch)))
+		}
+
+		batch = nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-queue:
+			batch = append(batch, event)
+
+			if len(batch) >= f.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// post sends a batch of events to a webhook, retrying with an exponential
+// backoff on failure.
+func (f *Forwarder) post(ctx context.Context, url string, events []*Event) error {
+	body, err := json.Marshal(&Payload{Events: events})
+	if err != nil {
+		return fmt.Errorf("failed to encode events: %w", err)
+	}
+
+	backoff := f.backoff
+
+	for attempt := 0; ; attempt++ {
+		err = f.do(ctx, url, body)
+		if err == nil {
+			f.requests.WithLabelValues("success").Inc()
+			return nil
+		}
+
+		f.requests.WithLabelValues("failure").Inc()
+
+		if attempt >= f.opts.MaxRetries {
+			return fmt.Errorf("failed to send %d events after %d attempts: %w", len(events), attempt+1, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
+
+func (f *Forwarder) do(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if f.opts.Secret != "" {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(f.opts.Secret, body))
+	}
+
+	resp, err := f.opts.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// Sign returns the hex-encoded HMAC-SHA256 signature of the body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
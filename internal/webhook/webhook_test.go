@@ -0,0 +1,249 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Tomy2e/livebox-api-client/api/request"
+	exporterLivebox "github.com/Tomy2e/livebox-exporter/pkg/livebox"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// fakeAPI is a Livebox API that only returns the events sent by the test.
+type fakeAPI struct {
+	events chan *exporterLivebox.EventMessage
+}
+
+func (fa *fakeAPI) Request(context.Context, *request.Request, any) error { return nil }
+
+func (fa *fakeAPI) Events(context.Context, []string) <-chan *exporterLivebox.EventMessage {
+	return fa.events
+}
+
+func (fa *fakeAPI) Session() exporterLivebox.SessionInfo { return exporterLivebox.SessionInfo{} }
+
+// received is a request received by a webhook.
+type received struct {
+	payload   Payload
+	signature string
+	body      []byte
+}
+
+// receiver returns a webhook that replies with the statuses, then with 200 OK,
+// and sends the received requests to the returned channel.
+func receiver(t *testing.T, statuses ...int) (*httptest.Server, <-chan *received) {
+	t.Helper()
+
+	requests := make(chan *received, 10)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		req := &received{signature: r.Header.Get(SignatureHeader), body: body}
+		if err := json.Unmarshal(body, &req.payload); err != nil {
+			t.Errorf("failed to decode payload: %s", err)
+		}
+
+		requests <- req
+
+		if len(statuses) > 0 {
+			w.WriteHeader(statuses[0])
+			statuses = statuses[1:]
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server, requests
+}
+
+// run runs the forwarder and returns a function that sends an event to it.
+func run(t *testing.T, f *Forwarder, api *fakeAPI) func(reason string) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		f.Run(ctx)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+		close(api.events)
+		<-done
+	})
+
+	return func(reason string) {
+		api.events <- &exporterLivebox.EventMessage{Event: &exporterLivebox.Event{
+			Handler: "Devices.Device.AA:BB:CC:00:00:01",
+			Reason:  reason,
+		}}
+	}
+}
+
+func wait(t *testing.T, requests <-chan *received) *received {
+	t.Helper()
+
+	select {
+	case req := <-requests:
+		return req
+	case <-time.After(5 * time.Second):
+		t.Fatal("no request was received")
+		return nil
+	}
+}
+
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	t.Helper()
+
+	m := &dto.Metric{}
+	if err := c.Write(m); err != nil {
+		t.Fatal(err)
+	}
+
+	return m.GetCounter().GetValue()
+}
+
+func TestSign(t *testing.T) {
+	// HMAC-SHA256 test vector.
+	const want = "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"
+
+	if got := Sign("key", []byte("The quick brown fox jumps over the lazy dog")); got != want {
+		t.Errorf("Sign() = %s, want %s", got, want)
+	}
+}
+
+// TestForwarderBatchSize checks that a batch is sent as soon as it is full,
+// and that it is signed.
+func TestForwarderBatchSize(t *testing.T) {
+	server, requests := receiver(t)
+	api := &fakeAPI{events: make(chan *exporterLivebox.EventMessage)}
+
+	f := NewForwarder(api, Options{
+		Channels:      []string{"Devices.Device"},
+		URLs:          []string{server.URL},
+		Secret:        "secret",
+		BatchSize:     2,
+		BatchInterval: time.Hour,
+	})
+
+	send := run(t, f, api)
+	send("connected")
+	send("disconnected")
+
+	req := wait(t, requests)
+
+	if len(req.payload.Events) != 2 {
+		t.Fatalf("received %d events, want 2", len(req.payload.Events))
+	}
+
+	if e := req.payload.Events[1]; e.Channel != "Devices.Device" || e.Reason != "disconnected" {
+		t.Errorf("unexpected event: %+v", e)
+	}
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(req.body)
+
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); req.signature != want {
+		t.Errorf("signature = %q, want %q", req.signature, want)
+	}
+}
+
+// TestForwarderBatchInterval checks that a batch that is not full is sent
+// after the batch interval, and that requests are not signed without secret.
+func TestForwarderBatchInterval(t *testing.T) {
+	server, requests := receiver(t)
+	api := &fakeAPI{events: make(chan *exporterLivebox.EventMessage)}
+
+	f := NewForwarder(api, Options{
+		Channels:      []string{"Devices.Device"},
+		URLs:          []string{server.URL},
+		BatchSize:     100,
+		BatchInterval: 50 * time.Millisecond,
+	})
+
+	send := run(t, f, api)
+	send("connected")
+
+	req := wait(t, requests)
+
+	if len(req.payload.Events) != 1 {
+		t.Errorf("received %d events, want 1", len(req.payload.Events))
+	}
+
+	if req.signature != "" {
+		t.Errorf("unsigned request has a signature: %q", req.signature)
+	}
+}
+
+// TestForwarderRetry checks that failed requests are retried, and that the
+// events are dropped once the retries are exhausted.
+func TestForwarderRetry(t *testing.T) {
+	for name, tc := range map[string]struct {
+		statuses []int
+		attempts int
+		dropped  float64
+	}{
+		"recovered": {
+			statuses: []int{http.StatusInternalServerError, http.StatusBadGateway},
+			attempts: 3,
+		},
+		"exhausted": {
+			statuses: []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError},
+			attempts: 3,
+			dropped:  1,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			server, requests := receiver(t, tc.statuses...)
+			api := &fakeAPI{events: make(chan *exporterLivebox.EventMessage)}
+
+			f := NewForwarder(api, Options{
+				Channels:      []string{"Devices.Device"},
+				URLs:          []string{server.URL},
+				BatchSize:     1,
+				BatchInterval: time.Hour,
+				MaxRetries:    2,
+			})
+			f.backoff = time.Millisecond
+
+			send := run(t, f, api)
+			send("connected")
+
+			for range tc.attempts {
+				if req := wait(t, requests); len(req.payload.Events) != 1 {
+					t.Fatalf("received %d events, want 1", len(req.payload.Events))
+				}
+			}
+
+			select {
+			case <-requests:
+				t.Fatal("received more requests than the maximum number of attempts")
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			failures := counterValue(t, f.requests.WithLabelValues("failure"))
+			if want := float64(len(tc.statuses)); failures != want {
+				t.Errorf("failed requests = %g, want %g", failures, want)
+			}
+
+			if dropped := counterValue(t, f.dropped); dropped != tc.dropped {
+				t.Errorf("dropped events = %g, want %g", dropped, tc.dropped)
+			}
+		})
+	}
+}
@@ -15,30 +15,36 @@ import (
 
 	"github.com/Tomy2e/livebox-api-client"
 	"github.com/Tomy2e/livebox-exporter/internal/collector"
+	"github.com/Tomy2e/livebox-exporter/internal/dashboard"
+	"github.com/Tomy2e/livebox-exporter/internal/fixture"
+	"github.com/Tomy2e/livebox-exporter/internal/influx"
+	"github.com/Tomy2e/livebox-exporter/internal/middleware"
+	"github.com/Tomy2e/livebox-exporter/internal/mqtt"
+	"github.com/Tomy2e/livebox-exporter/internal/otlp"
 	"github.com/Tomy2e/livebox-exporter/internal/poller"
+	"github.com/Tomy2e/livebox-exporter/internal/remotewrite"
+	"github.com/Tomy2e/livebox-exporter/internal/status"
+	"github.com/Tomy2e/livebox-exporter/internal/webhook"
 	exporterLivebox "github.com/Tomy2e/livebox-exporter/pkg/livebox"
+	"github.com/Tomy2e/livebox-exporter/pkg/oui"
+	"github.com/Tomy2e/livebox-exporter/pkg/reflect"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/exp/maps"
 	"golang.org/x/exp/slices"
 )
 
-const indexPage = `<html>
-	<head>
-		<meta charset="utf-8">
-		<title>Livebox exporter</title>
-	</head>
-
-	<body>
-		<h1>The livebox-exporter is working!</h1>
-
-		<p>See <a href="/metrics">/metrics</a> for metrics.</p>
-	</body>
-</html>
-`
-
 const defaultPollingFrequency = 30
 
+// defaultCollectorIntervals are the default intervals between each background
+// collection of the collectors.
+var defaultCollectorIntervals = map[string]time.Duration{
+	"DeviceInfo": 30 * time.Second,
+	"Devices":    30 * time.Second,
+	"ONT":        30 * time.Second,
+}
+
 const (
 	ExperimentalMetricsInterfaceHomeLan = "livebox_interface_homelan"
 	ExperimentalMetricsInterfaceNetDev  = "livebox_interface_netdev"
@@ -52,10 +58,9 @@ var experimentalMetrics = []string{
 }
 
 func parseExperimentalFlag(
-	client *livebox.Client,
+	client exporterLivebox.API,
 	interfaces []*exporterLivebox.Interface,
 	experimental string,
-	pollingFrequency *uint,
 ) (pollers []poller.Poller) {
 	if experimental == "" {
 		return nil
@@ -80,17 +85,8 @@ func parseExperimentalFlag(
 			pollers = append(pollers, poller.NewInterfaceHomeLanMbits(client, interfaces))
 		case ExperimentalMetricsInterfaceNetDev:
 			pollers = append(pollers, poller.NewInterfaceNetDevMbits(client, interfaces))
-
-			if *pollingFrequency > 5 {
-				log.Printf(
-					"WARN: The %s experimental metrics require a lower polling frequency, "+
-						"setting polling frequency to 5 seconds\n",
-					ExperimentalMetricsInterfaceNetDev,
-				)
-				*pollingFrequency = 5
-			}
 		case ExperimentalMetricsWAN:
-			pollers = append(pollers, poller.NewWANMbits(client))
+			pollers = append(pollers, poller.NewWANMbits(client, interfaces))
 		}
 
 		log.Printf("INFO: enabled experimental metrics: %s\n", exp)
@@ -100,6 +96,65 @@ func parseExperimentalFlag(
 	return
 }
 
+// splitList splits a comma separated list, ignoring empty values.
+func splitList(list string) (values []string) {
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return
+}
+
+// parseHeaders parses a comma separated list of key=value headers.
+func parseHeaders(list string) map[string]string {
+	headers := make(map[string]string)
+
+	for _, header := range splitList(list) {
+		if key, value, ok := strings.Cut(header, "="); ok {
+			headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+
+	return headers
+}
+
+// parseDurations parses a comma separated list of key=duration.
+func parseDurations(list string) (map[string]time.Duration, error) {
+	durations := make(map[string]time.Duration)
+
+	for key, value := range parseHeaders(list) {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration for %s: %w", key, err)
+		}
+
+		durations[key] = duration
+	}
+
+	return durations, nil
+}
+
+// formatDurations formats durations as a comma separated list of key=duration.
+func formatDurations(durations map[string]time.Duration) string {
+	list := make([]string, 0, len(durations))
+	for key, duration := range durations {
+		list = append(list, key+"="+duration.String())
+	}
+
+	slices.Sort(list)
+
+	return strings.Join(list, ",")
+}
+
+func webhookPresets() []string {
+	presets := maps.Keys(webhook.Presets)
+	slices.Sort(presets)
+
+	return presets
+}
+
 func getHTTPClient() (*http.Client, error) {
 	liveboxCACertPath := os.Getenv("LIVEBOX_CACERT")
 
@@ -141,17 +196,67 @@ func isFatalError(err error) bool {
 }
 
 func main() {
-	pollingFrequency := flag.Uint("polling-frequency", defaultPollingFrequency, "Polling frequency")
+	pollingFrequency := flag.Uint("polling-frequency", defaultPollingFrequency, "Polling frequency in seconds of the pollers without an interval in poller-intervals. Breaking change: if not set, pollers use their preferred interval instead of 30 seconds")
+	pollerIntervals := flag.String("poller-intervals", "", "Comma separated list of <poller>=<interval> intervals between each poll of the pollers")
+	stationStatsInterval := flag.Duration("station-stats-interval", 5*time.Second, "Interval between each poll of the Wi-Fi station stats")
+	deviceRatesTTL := flag.Duration("device-rates-ttl", 2*time.Minute, "Duration after which the rates of a device that are no longer updated are dropped")
+	deviceStateTTL := flag.Duration("device-state-ttl", 24*time.Hour, "Duration after which the accumulated traffic and the presence of a device that is no longer seen are forgotten")
 	listen := flag.String("listen", ":8080", "Listening address")
 	experimental := flag.String("experimental", "", fmt.Sprintf(
 		"Comma separated list of experimental metrics to enable (available metrics: %s)",
 		strings.Join(experimentalMetrics, ","),
 	))
+	ouiFile := flag.String("oui-file", "", "Optional path to an OUI database file that overrides the embedded database")
+	devicesConfigFile := flag.String("devices-config", "", "Optional path to a JSON file that configures device filters and aliases")
+	deviceTrafficFile := flag.String("device-traffic-file", "", "Optional path to a file where the accumulated traffic of devices is persisted")
+	webhookURLs := flag.String("webhook-urls", "", "Comma separated list of webhook URLs where Livebox events are forwarded")
+	webhookEvents := flag.String("webhook-events", "devices", fmt.Sprintf(
+		"Comma separated list of Livebox event channels forwarded to webhooks (presets: %s)",
+		strings.Join(webhookPresets(), ","),
+	))
+	webhookBatchSize := flag.Int("webhook-batch-size", 100, "Maximum number of events sent in a single webhook request")
+	webhookBatchInterval := flag.Duration("webhook-batch-interval", 5*time.Second, "Maximum delay before events are sent to webhooks")
+	webhookMaxRetries := flag.Int("webhook-max-retries", 5, "Maximum number of retries of a failed webhook request")
+	mqttBroker := flag.String("mqtt-broker", "", "Optional URL of an MQTT broker where values are published (e.g. tcp://localhost:1883)")
+	mqttTopicPrefix := flag.String("mqtt-topic-prefix", "livebox", "Prefix of the MQTT topics where values are published")
+	mqttDiscoveryPrefix := flag.String("mqtt-discovery-prefix", "homeassistant", "Home Assistant MQTT discovery prefix, set to an empty string to disable discovery")
+	mqttInterval := flag.Duration("mqtt-interval", 30*time.Second, "Interval between each publication of values to the MQTT broker")
+	otlpEndpoint := flag.String("otlp-endpoint", "", "Optional OTLP endpoint where metrics are pushed (e.g. http://localhost:4318/v1/metrics, or localhost:4317 with the grpc protocol)")
+	otlpProtocol := flag.String("otlp-protocol", otlp.ProtocolHTTPJSON, fmt.Sprintf("Protocol used to push metrics to the OTLP endpoint (%s, %s or %s)", otlp.ProtocolGRPC, otlp.ProtocolHTTPProtobuf, otlp.ProtocolHTTPJSON))
+	otlpInterval := flag.Duration("otlp-interval", 30*time.Second, "Interval between each push of metrics to the OTLP endpoint")
+	otlpSite := flag.String("otlp-site", "", "Optional site name added to the OTLP resource attributes")
+	influxURL := flag.String("influx-url", "", "Optional URL of an InfluxDB v2 server where metrics are written (e.g. http://localhost:8086)")
+	influxOrg := flag.String("influx-org", "", "InfluxDB organization")
+	influxBucket := flag.String("influx-bucket", "livebox", "InfluxDB bucket")
+	influxFile := flag.String("influx-file", "", "Optional path to a file where metrics are appended using the InfluxDB line protocol (- for stdout)")
+	influxInterval := flag.Duration("influx-interval", 30*time.Second, "Interval between each write of metrics to InfluxDB")
+	remoteWriteURL := flag.String("remote-write-url", "", "Optional Prometheus remote-write endpoint where metrics are pushed")
+	remoteWriteInterval := flag.Duration("remote-write-interval", 30*time.Second, "Interval between each push of metrics to the remote-write endpoint")
+	remoteWriteWALDir := flag.String("remote-write-wal-dir", "", "Optional directory where metrics are stored until they are pushed, metrics are stored in memory if empty")
+	remoteWriteWALMaxSize := flag.Int64("remote-write-wal-max-size", 100<<20, "Maximum size in bytes of the remote-write WAL directory")
+	collectorIntervals := flag.String("collector-intervals", formatDurations(defaultCollectorIntervals), "Comma separated list of <collector>=<interval> intervals between each background collection of the collectors")
+	staleIntervals := flag.Int("stale-intervals", 3, "Number of collector or poller intervals after which a series that is no longer updated is dropped")
+	sampleTimestamps := flag.Bool("sample-timestamps", false, "Add the time of the background collection to the samples of the collectors")
+	apiCacheTTLs := flag.String("api-cache-ttls", formatDurations(middleware.DefaultCacheTTLs), "Comma separated list of <service>.<method>=<ttl> TTLs of cached Livebox API responses, patterns are supported")
+	apiRateLimit := flag.Float64("api-rate-limit", 10, "Maximum number of Livebox API requests per second, 0 to disable the rate limit")
+	apiBurst := flag.Int("api-burst", 10, "Maximum number of Livebox API requests sent at once when the rate limit was not reached recently")
+	apiMaxInFlight := flag.Int("api-max-in-flight", 4, "Maximum number of concurrent Livebox API requests, 0 to disable the limit")
+	record := flag.String("record", "", "Optional path to a fixture file where the Livebox API traffic is recorded (credentials, keys, MACs, public IPs and serial numbers are redacted)")
+	replay := flag.String("replay", "", "Optional path to a fixture file replayed instead of connecting to the Livebox")
 	flag.Parse()
 
+	if *record != "" && *replay != "" {
+		log.Fatal("record and replay are mutually exclusive")
+	}
+
 	adminPassword := os.Getenv("ADMIN_PASSWORD")
 	if adminPassword == "" {
-		log.Fatal("ADMIN_PASSWORD environment variable must be set")
+		if *replay == "" {
+			log.Fatal("ADMIN_PASSWORD environment variable must be set")
+		}
+
+		// Credentials are redacted from fixtures, any password works.
+		adminPassword = "redacted"
 	}
 
 	liveboxAddress := os.Getenv("LIVEBOX_ADDRESS")
@@ -163,26 +268,94 @@ func main() {
 		log.Fatal("polling-frequency must be between 1 and 300 seconds")
 	}
 
+	if *stationStatsInterval <= 0 {
+		log.Fatal("station-stats-interval must be positive")
+	}
+
+	if *webhookBatchInterval <= 0 {
+		log.Fatal("webhook-batch-interval must be positive")
+	}
+
+	if *mqttInterval <= 0 {
+		log.Fatal("mqtt-interval must be positive")
+	}
+
+	if *otlpInterval <= 0 {
+		log.Fatal("otlp-interval must be positive")
+	}
+
+	if *influxInterval <= 0 {
+		log.Fatal("influx-interval must be positive")
+	}
+
+	if *remoteWriteInterval <= 0 {
+		log.Fatal("remote-write-interval must be positive")
+	}
+
+	if *deviceRatesTTL <= 0 {
+		log.Fatal("device-rates-ttl must be positive")
+	}
+
+	if *deviceStateTTL <= 0 {
+		log.Fatal("device-state-ttl must be positive")
+	}
+
+	pollerOverrides, err := parseDurations(*pollerIntervals)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	httpClient, err := getHTTPClient()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	client, err := livebox.NewClient(
-		adminPassword,
-		livebox.WithAddress(liveboxAddress),
-		livebox.WithHTTPClient(httpClient),
-	)
+	switch {
+	case *record != "":
+		recorder, err := fixture.NewRecorder(*record, httpClient.Transport)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		httpClient = &http.Client{Transport: recorder}
+		log.Printf("INFO: recording Livebox API traffic to %s\n", *record)
+	case *replay != "":
+		replayer, err := fixture.NewReplayer(*replay)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		httpClient = &http.Client{Transport: replayer}
+		log.Printf("INFO: replaying %d Livebox API methods from %s\n", len(replayer.Methods()), *replay)
+	}
+
+	liveboxClient, err := exporterLivebox.NewClient(adminPassword, liveboxAddress, httpClient)
 	if err != nil {
 		log.Fatalf("Failed to create Livebox client: %v", err)
 	}
 
+	cacheTTLs, err := parseDurations(*apiCacheTTLs)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	limiter := middleware.NewLimiter(middleware.LimiterOptions{
+		Rate:        *apiRateLimit,
+		Burst:       *apiBurst,
+		MaxInFlight: *apiMaxInFlight,
+	})
+
+	instrumentation := middleware.NewInstrumentation()
+
+	client := exporterLivebox.Chain(liveboxClient,
+		middleware.Cache(cacheTTLs),
+		limiter.Middleware(),
+		instrumentation.Middleware(),
+	)
+
 	var (
 		ctx      = context.Background()
 		registry = prometheus.NewRegistry()
-		pollers  = poller.Pollers{
-			poller.NewInterfaceMbits(client),
-		}
 	)
 
 	interfaces, err := exporterLivebox.DiscoverInterfaces(ctx, client)
@@ -190,8 +363,15 @@ func main() {
 		log.Fatalf("Failed to discover Livebox interfaces: %s\n", err)
 	}
 
+	pollers := poller.Pollers{
+		poller.NewInterfaceMbits(client),
+	}
+
 	// Add experimental pollers.
-	pollers = append(pollers, parseExperimentalFlag(client, interfaces, *experimental, pollingFrequency)...)
+	pollers = append(pollers, parseExperimentalFlag(client, interfaces, *experimental)...)
+
+	monitor := poller.NewMonitor()
+	pollers = monitor.Wrap(pollers)
 
 	registry.MustRegister(
 		append(
@@ -201,6 +381,9 @@ func main() {
 		)...,
 	)
 
+	registry.MustRegister(limiter.Collectors()...)
+	registry.MustRegister(instrumentation.Collectors()...)
+
 	writeHeaderVec := prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
 			Name:    "promhttp_metric_handler_write_header_duration_seconds",
@@ -210,36 +393,219 @@ func main() {
 		[]string{"code"},
 	)
 
-	registry.MustRegister(
+	devicesOptions := []collector.DevicesOption{
+		collector.WithContext(ctx),
+		collector.WithStationStatsInterval(*stationStatsInterval),
+		collector.WithRatesTTL(*deviceRatesTTL),
+		collector.WithStateTTL(*deviceStateTTL),
+	}
+	if *deviceTrafficFile != "" {
+		devicesOptions = append(devicesOptions, collector.WithTrafficFile(*deviceTrafficFile))
+	}
+
+	if *devicesConfigFile != "" {
+		devicesConfig, err := collector.LoadDevicesConfig(*devicesConfigFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		devicesOptions = append(devicesOptions, collector.WithConfig(devicesConfig))
+	}
+
+	if *ouiFile != "" {
+		ouiDatabase, err := oui.Load(*ouiFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		devicesOptions = append(devicesOptions, collector.WithOUIDatabase(ouiDatabase))
+	} else if oui.Default().Len() == 0 {
+		// Device vendors would silently be missing from the metrics.
+		log.Fatal("the embedded OUI database is empty, run go generate ./pkg/oui before building or use the -oui-file option (-oui-file /dev/null disables the vendor lookup)")
+	}
+
+	intervals, err := parseDurations(*collectorIntervals)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, c := range []prometheus.Collector{
 		collector.NewDeviceInfo(client),
-		collector.NewDevices(client, interfaces),
+		collector.NewDevices(client, interfaces, devicesOptions...),
 		collector.NewONT(client, interfaces),
-		writeHeaderVec,
-	)
+	} {
+		name := reflect.GetType(c)
+
+		interval, ok := intervals[name]
+		if !ok {
+			interval = defaultCollectorIntervals[name]
+		}
+
+		if interval <= 0 {
+			log.Fatalf("collector interval of %s must be positive", name)
+		}
+
+		snapshot := collector.NewSnapshot(c, collector.SnapshotOptions{
+			Interval:       interval,
+			StaleIntervals: *staleIntervals,
+			Timestamps:     *sampleTimestamps,
+		})
+		registry.MustRegister(snapshot)
+
+		go snapshot.Run(ctx)
+	}
+
+	registry.MustRegister(writeHeaderVec)
 
-	go func() {
-		for {
-			if err := pollers.Poll(ctx); err != nil {
-				if isFatalError(err) {
-					log.Fatal(err)
-				}
+	if urls := splitList(*webhookURLs); len(urls) > 0 {
+		forwarder := webhook.NewForwarder(client, webhook.Options{
+			Channels:      webhook.ExpandChannels(splitList(*webhookEvents)),
+			URLs:          urls,
+			Secret:        os.Getenv("WEBHOOK_SECRET"),
+			BatchSize:     *webhookBatchSize,
+			BatchInterval: *webhookBatchInterval,
+			MaxRetries:    *webhookMaxRetries,
+		})
 
-				log.Printf("WARN: polling failed: %s\n", err)
+		registry.MustRegister(forwarder.Collectors()...)
+
+		go forwarder.Run(ctx)
+	}
+
+	if *mqttBroker != "" {
+		clientOpts := mqtt.ClientOptions{
+			Broker:   *mqttBroker,
+			ClientID: "livebox-exporter",
+			Username: os.Getenv("MQTT_USERNAME"),
+			Password: os.Getenv("MQTT_PASSWORD"),
+		}
+
+		if err := clientOpts.Validate(); err != nil {
+			log.Fatalf("Invalid MQTT options: %s", err)
+		}
+
+		publisher := mqtt.NewPublisher(registry, mqtt.Options{
+			Client:          clientOpts,
+			TopicPrefix:     *mqttTopicPrefix,
+			DiscoveryPrefix: *mqttDiscoveryPrefix,
+			Interval:        *mqttInterval,
+		})
+
+		go publisher.Run(ctx)
+	}
+
+	if *otlpEndpoint != "" {
+		resourceAttributes := map[string]string{"service.name": "livebox-exporter"}
+
+		if *otlpSite != "" {
+			resourceAttributes["livebox.site"] = *otlpSite
+		}
+
+		if deviceInfo, err := exporterLivebox.GetDeviceInfo(ctx, client); err != nil {
+			log.Printf("WARN: Failed to identify the Livebox: %s\n", err)
+		} else {
+			resourceAttributes["livebox.serial"] = deviceInfo.SerialNumber
+			resourceAttributes["livebox.model"] = deviceInfo.ModelName
+		}
+
+		exporter, err := otlp.NewExporter(registry, otlp.Options{
+			Endpoint:           *otlpEndpoint,
+			Protocol:           *otlpProtocol,
+			Headers:            parseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")),
+			Interval:           *otlpInterval,
+			ResourceAttributes: resourceAttributes,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		go exporter.Run(ctx)
+	}
+
+	var influxSink influx.Sink
+
+	switch {
+	case *influxURL != "" && *influxFile != "":
+		log.Fatal("influx-url and influx-file are mutually exclusive")
+	case *influxURL != "":
+		influxSink = &influx.HTTPSink{
+			URL:    *influxURL,
+			Org:    *influxOrg,
+			Bucket: *influxBucket,
+			Token:  os.Getenv("INFLUX_TOKEN"),
+		}
+	case *influxFile != "":
+		influxSink = &influx.FileSink{Path: *influxFile}
+	}
+
+	if influxSink != nil {
+		go influx.NewWriter(registry, influxSink, *influxInterval).Run(ctx)
+	}
+
+	if *remoteWriteURL != "" {
+		pusher, err := remotewrite.NewPusher(registry, remotewrite.Options{
+			URL:         *remoteWriteURL,
+			Username:    os.Getenv("REMOTE_WRITE_USERNAME"),
+			Password:    os.Getenv("REMOTE_WRITE_PASSWORD"),
+			BearerToken: os.Getenv("REMOTE_WRITE_BEARER_TOKEN"),
+			Interval:    *remoteWriteInterval,
+			WALDir:      *remoteWriteWALDir,
+			WALMaxSize:  *remoteWriteWALMaxSize,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		go pusher.Run(ctx)
+	}
+
+	// The polling frequency applies to the pollers without an interval only
+	// when it is explicitly set.
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name != "polling-frequency" {
+			return
+		}
+
+		for _, p := range pollers {
+			if _, ok := pollerOverrides[poller.Name(p)]; !ok {
+				pollerOverrides[poller.Name(p)] = time.Duration(*pollingFrequency) * time.Second
 			}
+		}
+	})
 
-			time.Sleep(time.Duration(*pollingFrequency) * time.Second)
+	scheduler := poller.NewScheduler(pollers, pollerOverrides, *staleIntervals)
+	go scheduler.Run(ctx, func(name string, err error) {
+		if isFatalError(err) {
+			log.Fatalf("%s: %s", name, err)
 		}
-	}()
+
+		log.Printf("WARN: polling %s failed: %s\n", name, err)
+	})
 
 	http.Handle("/metrics", promhttp.InstrumentHandlerTimeToWriteHeader(writeHeaderVec,
 		promhttp.InstrumentMetricHandler(
 			registry, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}),
 		)),
 	)
-	http.HandleFunc("/{$}", func(w http.ResponseWriter, _ *http.Request) {
-		_, _ = w.Write([]byte(indexPage))
+	statusHandler := status.NewHandler(client, registry, interfaces)
+	go statusHandler.Run(ctx)
+	dash := dashboard.New(dashboard.Options{
+		Status:  statusHandler,
+		Monitor: monitor,
+		Config: append(
+			dashboard.FlagSettings(flag.CommandLine, "webhook-urls"),
+			dashboard.EnvSettings(
+				[]string{"LIVEBOX_ADDRESS", "LIVEBOX_CACERT", "MQTT_USERNAME", "REMOTE_WRITE_USERNAME"},
+				[]string{"ADMIN_PASSWORD", "WEBHOOK_SECRET", "MQTT_PASSWORD", "OTEL_EXPORTER_OTLP_HEADERS", "INFLUX_TOKEN", "REMOTE_WRITE_PASSWORD", "REMOTE_WRITE_BEARER_TOKEN"},
+			)...,
+		),
+		Interval: time.Duration(*pollingFrequency) * time.Second,
 	})
 
+	http.Handle("/api/v1/status", statusHandler)
+	http.HandleFunc("/api/v1/events", dash.ServeEvents)
+	http.HandleFunc("/{$}", dash.ServeIndex)
+
 	log.Printf("Listening on %s\n", *listen)
 	log.Fatal(http.ListenAndServe(*listen, nil))
 }
@@ -0,0 +1,96 @@
+package bitrate
+
+import "time"
+
+// Accumulator turns raw Livebox counters, which can wrap or be reset, into
+// monotonic increases that can be added to Prometheus counters. The counters
+// are assumed to come from the same source, so they all have the same width.
+// This implementation is not thread-safe.
+type Accumulator struct {
+	counters map[string]*counterState
+	source   source
+}
+
+// NewAccumulator returns a new counter accumulator.
+func NewAccumulator() *Accumulator {
+	return &Accumulator{
+		counters: make(map[string]*counterState),
+	}
+}
+
+// SetUptime saves the current uptime of the Livebox, see Bitrate.SetUptime.
+func (a *Accumulator) SetUptime(uptime time.Duration) {
+	a.source.set(uptime)
+}
+
+// Increase saves the current value of the counter and returns how much it
+// increased since the previous call. The first call for a counter returns the
+// current value, so that exported counters start at the Livebox value.
+func (a *Accumulator) Increase(name string, current uint64) uint64 {
+	now := time.Now()
+
+	state, ok := a.counters[name]
+	if !ok {
+		state = &counterState{}
+		a.counters[name] = state
+	}
+
+	increase := current
+	if ok {
+		increase, _ = state.increase(current, &a.source, now)
+	}
+
+	state.update(current, &a.source, now)
+
+	return increase
+}
+
+// Increases returns the Tx and Rx increases of the named counters.
+func (a *Accumulator) Increases(name string, current *Counters) *Counters {
+	return &Counters{
+		Tx: a.Increase(name+"/tx", current.Tx),
+		Rx: a.Increase(name+"/rx", current.Rx),
+	}
+}
+
+// Delete removes a counter, or the Tx and Rx counters of Increases. The next
+// increase of a deleted counter is its current value.
+func (a *Accumulator) Delete(name string) {
+	delete(a.counters, name)
+	delete(a.counters, name+"/tx")
+	delete(a.counters, name+"/rx")
+}
+
+// State is the saved state of a counter, it allows accumulating counters
+// across restarts of the exporter.
+type State struct {
+	Value uint64    `json:"value"`
+	Time  time.Time `json:"time"`
+}
+
+// States returns the state of all counters.
+func (a *Accumulator) States() map[string]State {
+	states := make(map[string]State, len(a.counters))
+
+	for name, state := range a.counters {
+		states[name] = State{
+			Value: state.value,
+			Time:  state.last,
+		}
+	}
+
+	return states
+}
+
+// Restore restores the state of counters saved with States. The Livebox
+// uptime is unknown at this point and the Livebox may have been rebooted since
+// the states were saved, so a restored counter that decreased is always
+// considered as reset.
+func (a *Accumulator) Restore(states map[string]State) {
+	for name, s := range states {
+		state := &counterState{}
+		state.update(s.Value, &a.source, s.Time)
+		state.restored = true
+		a.counters[name] = state
+	}
+}
@@ -0,0 +1,57 @@
+package bitrate
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAccumulatorRestore(t *testing.T) {
+	a := NewAccumulator()
+	a.Restore(map[string]State{
+		"eth0/rx": {Value: math.MaxUint32 - 999, Time: time.Now().Add(-time.Hour)},
+	})
+
+	if got := a.Increase("eth0/rx", 500); got != 500 {
+		t.Errorf("Increase() = %d, want 500 after a restore", got)
+	}
+
+	if got := a.Increase("eth0/rx", 1500); got != 1000 {
+		t.Errorf("Increase() = %d, want 1000", got)
+	}
+}
+
+func TestAccumulatorDelete(t *testing.T) {
+	a := NewAccumulator()
+	a.Increases("dev", &Counters{Tx: 1000, Rx: 1000})
+	a.Delete("dev")
+
+	if len(a.States()) != 0 {
+		t.Fatalf("States() = %v, want no state", a.States())
+	}
+
+	if got := a.Increases("dev", &Counters{Tx: 1500, Rx: 1500}); got.Tx != 1500 {
+		t.Errorf("Increases() = %d, want 1500 after a delete", got.Tx)
+	}
+}
+
+func TestAccumulatorSharedWidth(t *testing.T) {
+	for _, is64 := range []bool{false, true} {
+		a := NewAccumulator()
+		a.Increase("eth1/rx", math.MaxUint32-999)
+		a.counters["eth1/rx"].last = time.Now().Add(-30 * time.Second)
+
+		if is64 {
+			a.Increase("eth0/rx", math.MaxUint32+1)
+		}
+
+		want := uint64(1500)
+		if is64 {
+			want = 500
+		}
+
+		if got := a.Increase("eth1/rx", 500); got != want {
+			t.Errorf("64-bit source %t: Increase() = %d, want %d", is64, got, want)
+		}
+	}
+}
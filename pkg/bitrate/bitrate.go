@@ -4,25 +4,25 @@ import (
 	"time"
 )
 
-// Bitrate allows calculating bitrates for a set of network interfaces.
-// This implementation is not thread-safe.
+// Bitrate allows calculating bitrates for a set of network interfaces. The
+// counters are assumed to come from the same source, so they all have the same
+// width. This implementation is not thread-safe.
 type Bitrate struct {
-	measures                map[string]*measure
-	minDelayBetweenMeasures time.Duration
+	measures map[string]*measure
+	source   source
 }
 
 // New returns a new bitrate measurer.
-func New(minDelayBetweenMeasures time.Duration) *Bitrate {
+func New() *Bitrate {
 	return &Bitrate{
-		measures:                make(map[string]*measure),
-		minDelayBetweenMeasures: minDelayBetweenMeasures,
+		measures: make(map[string]*measure),
 	}
 }
 
 // mesure saves the counter values at a specific point in time.
 type measure struct {
-	Counters
-	Last time.Time
+	Tx, Rx counterState
+	Last   time.Time
 }
 
 // Counters contain Tx and Rx counters for a network interface.
@@ -49,48 +49,43 @@ type BitrateSpec struct {
 	Value float64
 	// Reset is true when the counter was reset.
 	Reset bool
+	// Wrapped is true when the counter wrapped, the bitrate is still valid.
+	Wrapped bool
 }
 
-// ShouldMeasure returns true if a measure should be done.
-func (b *Bitrate) ShouldMeasure(name string) bool {
-	last, ok := b.measures[name]
-	if !ok {
-		return true
-	}
+// SetUptime saves the current uptime of the Livebox. A decreasing uptime means
+// the Livebox was rebooted and that its counters were reset, which allows to
+// tell counter resets from counter wraps.
+func (b *Bitrate) SetUptime(uptime time.Duration) {
+	b.source.set(uptime)
+}
+
+// Expire removes the measures that were not updated within ttl, for example
+// the measures of devices that are disconnected.
+func (b *Bitrate) Expire(ttl time.Duration) {
+	deadline := time.Now().Add(-ttl)
 
-	return time.Since(last.Last) > b.minDelayBetweenMeasures
+	for name, last := range b.measures {
+		if last.Last.Before(deadline) {
+			delete(b.measures, name)
+		}
+	}
 }
 
 // Measure saves the current measure and returns the current RX/TX bitrates.
 func (b *Bitrate) Measure(name string, current *Counters) *Bitrates {
 	br := &Bitrates{}
+	now := time.Now()
 
 	last, ok := b.measures[name]
 
 	// Only calculate bitrates if there is a previous measure.
 	if ok && !last.Last.IsZero() {
-		elapsed := time.Since(last.Last)
+		elapsed := now.Sub(last.Last)
 
 		if elapsed.Seconds() > 0 && elapsed.Minutes() <= 6 {
-			if diff := int64(current.Rx - last.Rx); diff >= 0 {
-				br.Rx = &BitrateSpec{
-					Value: BytesPerSecToMbits(float64(diff) / elapsed.Seconds()),
-				}
-			} else {
-				br.Rx = &BitrateSpec{
-					Reset: true,
-				}
-			}
-
-			if diff := int64(current.Tx - last.Tx); diff >= 0 {
-				br.Tx = &BitrateSpec{
-					Value: BytesPerSecToMbits(float64(diff) / elapsed.Seconds()),
-				}
-			} else {
-				br.Tx = &BitrateSpec{
-					Reset: true,
-				}
-			}
+			br.Rx = b.spec(&last.Rx, current.Rx, now, elapsed)
+			br.Tx = b.spec(&last.Tx, current.Tx, now, elapsed)
 		}
 
 		// Sanitize bitrates: we assume bitrates cannot be above 10000 Mbit/s.
@@ -103,11 +98,30 @@ func (b *Bitrate) Measure(name string, current *Counters) *Bitrates {
 		}
 	}
 
-	// Save this measure as the latest.
-	b.measures[name] = &measure{
-		Counters: *current,
-		Last:     time.Now(),
+	if !ok {
+		last = &measure{}
+		b.measures[name] = last
 	}
 
+	// Save this measure as the latest.
+	last.Rx.update(current.Rx, &b.source, now)
+	last.Tx.update(current.Tx, &b.source, now)
+	last.Last = now
+
 	return br
 }
+
+// spec returns the bitrate of a counter since its last state.
+func (b *Bitrate) spec(last *counterState, current uint64, now time.Time, elapsed time.Duration) *BitrateSpec {
+	diff, change := last.increase(current, &b.source, now)
+	if change == reset {
+		return &BitrateSpec{
+			Reset: true,
+		}
+	}
+
+	return &BitrateSpec{
+		Value:   BytesPerSecToMbits(float64(diff) / elapsed.Seconds()),
+		Wrapped: change == wrapped,
+	}
+}
@@ -0,0 +1,112 @@
+package bitrate
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMeasure(t *testing.T) {
+	tests := []struct {
+		name          string
+		previous      uint64
+		current       uint64
+		wantValue     float64
+		wantReset     bool
+		wantWrapped   bool
+		wantAvailable bool
+	}{
+		{
+			name:          "increase",
+			previous:      1000,
+			current:       1000 + 125_000*30,
+			wantValue:     1,
+			wantAvailable: true,
+		},
+		{
+			name:          "32-bit wrap",
+			previous:      math.MaxUint32 - 125_000*10 + 1,
+			current:       125_000 * 20,
+			wantValue:     1,
+			wantWrapped:   true,
+			wantAvailable: true,
+		},
+		{
+			name:          "reset",
+			previous:      100_000_000,
+			current:       5000,
+			wantReset:     true,
+			wantAvailable: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := New()
+			b.Measure("eth0", &Counters{Rx: tt.previous, Tx: tt.previous})
+
+			// Pretend the previous measure was done 30 seconds ago.
+			last := b.measures["eth0"]
+			last.Last = last.Last.Add(-30 * time.Second)
+			last.Rx.last = last.Last
+			last.Tx.last = last.Last
+
+			br := b.Measure("eth0", &Counters{Rx: tt.current, Tx: tt.current})
+
+			for _, spec := range []*BitrateSpec{br.Rx, br.Tx} {
+				if (spec != nil) != tt.wantAvailable {
+					t.Fatalf("bitrate available = %t, want %t", spec != nil, tt.wantAvailable)
+				}
+
+				if spec.Reset != tt.wantReset || spec.Wrapped != tt.wantWrapped {
+					t.Errorf("Reset = %t, Wrapped = %t, want %t, %t", spec.Reset, spec.Wrapped, tt.wantReset, tt.wantWrapped)
+				}
+
+				if math.Abs(spec.Value-tt.wantValue) > 0.01 {
+					t.Errorf("Value = %f, want %f", spec.Value, tt.wantValue)
+				}
+			}
+		})
+	}
+}
+
+func TestMeasureFirst(t *testing.T) {
+	br := New().Measure("eth0", &Counters{Rx: 1000, Tx: 1000})
+	if br.Rx != nil || br.Tx != nil {
+		t.Errorf("Measure() = %+v, want no bitrates on the first measure", br)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	b := New()
+	b.Measure("gone", &Counters{})
+	b.Measure("active", &Counters{})
+
+	b.measures["gone"].Last = time.Now().Add(-time.Hour)
+	b.Expire(time.Minute)
+
+	if _, ok := b.measures["gone"]; ok {
+		t.Error("measure of gone was not removed")
+	}
+
+	if _, ok := b.measures["active"]; !ok {
+		t.Error("measure of active was removed")
+	}
+}
+
+// TestMeasureSharedWidth checks that a decrease of a counter is a reset once
+// another counter of the source was seen above math.MaxUint32.
+func TestMeasureSharedWidth(t *testing.T) {
+	b := New()
+	b.Measure("eth0", &Counters{Rx: math.MaxUint32 + 1, Tx: 1000})
+	b.Measure("eth1", &Counters{Rx: math.MaxUint32 - 125_000*10 + 1, Tx: 1000})
+
+	last := b.measures["eth1"]
+	last.Last = last.Last.Add(-30 * time.Second)
+	last.Rx.last = last.Last
+
+	br := b.Measure("eth1", &Counters{Rx: 125_000 * 20, Tx: 1000})
+	if br.Rx == nil || !br.Rx.Reset || br.Rx.Wrapped {
+		t.Errorf("Rx = %+v, want a reset", br.Rx)
+	}
+}
@@ -0,0 +1,119 @@
+package bitrate
+
+import (
+	"math"
+	"time"
+)
+
+// maxBytesPerSec is the highest throughput considered plausible (10000 Mbit/s),
+// it is used to tell counter wraps from counter resets.
+const maxBytesPerSec = 10000 * 1000000 / 8
+
+// wrapThreshold is the middle of the 32-bit range. A 32-bit counter wraps from
+// the upper half of the range to the lower half.
+const wrapThreshold = 1 << 31
+
+// width is the width in bits of the counters of a source.
+type width uint8
+
+const (
+	// widthUnknown is used until a counter of the source was seen above
+	// math.MaxUint32. Such counters are assumed to be 32-bit counters when
+	// they decrease.
+	widthUnknown width = 0
+	width64      width = 64
+)
+
+// detect returns the width of the counters after observing the given value.
+func (w width) detect(value uint64) width {
+	if value > math.MaxUint32 {
+		return width64
+	}
+
+	return w
+}
+
+// change describes how a counter evolved between two measures.
+type change uint8
+
+const (
+	increased change = iota
+	wrapped
+	reset
+)
+
+// counterState is the last known state of a single counter.
+type counterState struct {
+	value uint64
+	boots uint64
+	last  time.Time
+	// restored is true when the state was restored from a previous run of the
+	// exporter, the source may have been rebooted in the meantime so any
+	// decrease is a reset.
+	restored bool
+}
+
+// increase returns how much a counter increased between the last state and the
+// current value, and whether the counter wrapped or was reset in the meantime.
+//
+// A decreasing counter is considered as wrapped when the counters of the
+// source can be 32-bit counters, the source was not rebooted, the previous
+// value was in the upper half of the 32-bit range, the current value is in the
+// lower half and the increase computed across the wrap is a plausible
+// throughput. Otherwise the counter was reset and is assumed to have restarted
+// from 0.
+func (s *counterState) increase(current uint64, src *source, now time.Time) (uint64, change) {
+	rebooted := src.boots != s.boots
+
+	switch {
+	case rebooted:
+		return current, reset
+	case current >= s.value:
+		return current - s.value, increased
+	case src.width == width64, s.restored:
+		return current, reset
+	case s.value < wrapThreshold, current >= wrapThreshold:
+		return current, reset
+	}
+
+	diff := math.MaxUint32 - s.value + current + 1
+
+	if elapsed := now.Sub(s.last).Seconds(); elapsed > 0 && float64(diff)/elapsed <= maxBytesPerSec {
+		return diff, wrapped
+	}
+
+	return current, reset
+}
+
+// update saves the current value of the counter, and detects the width of the
+// counters of the source.
+func (s *counterState) update(current uint64, src *source, now time.Time) {
+	src.width = src.width.detect(current)
+	s.value = current
+	s.boots = src.boots
+	s.last = now
+	s.restored = false
+}
+
+// uptime tracks the uptime of the counters source to detect reboots.
+type uptime struct {
+	last  time.Duration
+	boots uint64
+}
+
+// set saves the current uptime of the source, a decreasing uptime means the
+// source was rebooted and that all its counters were reset.
+func (u *uptime) set(current time.Duration) {
+	if current < u.last {
+		u.boots++
+	}
+
+	u.last = current
+}
+
+// source is the state shared by the counters of a source: its uptime and the
+// width of its counters, which is the same for all of them.
+type source struct {
+	uptime
+	width width
+}
@@ -0,0 +1,171 @@
+package bitrate
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCounterStateIncrease(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		previous uint64
+		width    width
+		restored bool
+		elapsed  time.Duration
+		current  uint64
+		rebooted bool
+		want     uint64
+		change   change
+	}{
+		{
+			name:     "plain increase",
+			previous: 1000,
+			elapsed:  30 * time.Second,
+			current:  5000,
+			want:     4000,
+			change:   increased,
+		},
+		{
+			name:     "32-bit wrap",
+			previous: math.MaxUint32 - 999,
+			elapsed:  30 * time.Second,
+			current:  500,
+			want:     1500,
+			change:   wrapped,
+		},
+		{
+			name:     "reset without reboot",
+			previous: 100_000_000,
+			elapsed:  5 * time.Second,
+			current:  5000,
+			want:     5000,
+			change:   reset,
+		},
+		{
+			name:     "reset without reboot after a long delay",
+			previous: 100_000_000,
+			elapsed:  30 * time.Second,
+			current:  5000,
+			want:     5000,
+			change:   reset,
+		},
+		{
+			name:     "reset with a lower uptime",
+			previous: math.MaxUint32 - 999,
+			elapsed:  30 * time.Second,
+			current:  500,
+			rebooted: true,
+			want:     500,
+			change:   reset,
+		},
+		{
+			name:     "implausible wrap",
+			previous: math.MaxUint32 - 999,
+			elapsed:  time.Millisecond,
+			current:  wrapThreshold - 1,
+			want:     wrapThreshold - 1,
+			change:   reset,
+		},
+		{
+			name:     "64-bit counter decrease",
+			previous: math.MaxUint32 - 999,
+			width:    width64,
+			elapsed:  30 * time.Second,
+			current:  500,
+			want:     500,
+			change:   reset,
+		},
+		{
+			name:     "restored counter decrease",
+			previous: math.MaxUint32 - 999,
+			restored: true,
+			elapsed:  30 * time.Second,
+			current:  500,
+			want:     500,
+			change:   reset,
+		},
+		{
+			name:     "restored counter increase",
+			previous: 1000,
+			restored: true,
+			elapsed:  30 * time.Second,
+			current:  5000,
+			want:     4000,
+			change:   increased,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var src source
+			src.set(time.Hour)
+
+			s := &counterState{}
+			s.update(tt.previous, &src, now.Add(-tt.elapsed))
+			src.width = tt.width
+			s.restored = tt.restored
+
+			if tt.rebooted {
+				src.set(time.Minute)
+			}
+
+			got, change := s.increase(tt.current, &src, now)
+			if got != tt.want || change != tt.change {
+				t.Errorf("increase() = %d, %d, want %d, %d", got, change, tt.want, tt.change)
+			}
+		})
+	}
+}
+
+func TestWidthDetect(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []uint64
+		want   width
+	}{
+		{name: "unknown", values: []uint64{0, 1000, math.MaxUint32}, want: widthUnknown},
+		{name: "64-bit", values: []uint64{1000, math.MaxUint32 + 1}, want: width64},
+		{name: "64-bit is kept", values: []uint64{math.MaxUint32 + 1, 1000}, want: width64},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var src source
+
+			s := &counterState{}
+			for _, value := range tt.values {
+				s.update(value, &src, time.Now())
+			}
+
+			if src.width != tt.want {
+				t.Errorf("width = %d, want %d", src.width, tt.want)
+			}
+		})
+	}
+}
+
+// TestWidthShared checks that the width detected from a counter applies to the
+// other counters of the source, which have not been seen above
+// math.MaxUint32.
+func TestWidthShared(t *testing.T) {
+	now := time.Now()
+
+	var src source
+
+	large, small := &counterState{}, &counterState{}
+	small.update(math.MaxUint32-999, &src, now.Add(-30*time.Second))
+
+	// The decrease of small would be a plausible wrap of a 32-bit counter.
+	if _, change := small.increase(500, &src, now); change != wrapped {
+		t.Fatalf("change of an unknown width counter = %d, want %d", change, wrapped)
+	}
+
+	large.update(math.MaxUint32+1, &src, now.Add(-30*time.Second))
+
+	if got, change := small.increase(500, &src, now); got != 500 || change != reset {
+		t.Errorf("increase() = %d, %d, want 500, %d", got, change, reset)
+	}
+}
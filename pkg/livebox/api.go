@@ -0,0 +1,194 @@
+package livebox
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Tomy2e/livebox-api-client"
+	"github.com/Tomy2e/livebox-api-client/api/request"
+)
+
+// API is the Livebox API used by the pollers and collectors of the exporter.
+type API interface {
+	// Request sends a request to the Livebox and decodes its response into
+	// out.
+	Request(ctx context.Context, req *request.Request, out any) error
+	// Events returns the events of the channels. The returned channel is
+	// closed when the context is canceled.
+	Events(ctx context.Context, channels []string) <-chan *EventMessage
+	// Session returns information about the session on the Livebox.
+	Session() SessionInfo
+}
+
+// Event is a Livebox event.
+type Event struct {
+	Handler    string
+	Reason     string
+	Attributes map[string]any
+}
+
+// EventMessage contains an event or the error that occurred while getting
+// events.
+type EventMessage struct {
+	Event *Event
+	Error error
+}
+
+// SessionInfo contains information about the session on the Livebox.
+type SessionInfo struct {
+	// Address of the Livebox.
+	Address string `json:"address"`
+	// Logins is the number of sessions created, LastLogin is the time when
+	// the last session was created.
+	Logins    uint64    `json:"logins"`
+	LastLogin time.Time `json:"last_login"`
+}
+
+// Client implements API using a Livebox API client.
+type Client struct {
+	client  *livebox.Client
+	address string
+	session *sessionTransport
+}
+
+var _ API = &Client{}
+
+// NewClient returns a new Client for the Livebox at the address, requests are
+// sent using the HTTP client.
+func NewClient(password, address string, httpClient *http.Client) (*Client, error) {
+	session := &sessionTransport{next: httpClient.Transport}
+	if session.next == nil {
+		session.next = http.DefaultTransport
+	}
+
+	client, err := livebox.NewClient(
+		password,
+		livebox.WithAddress(address),
+		livebox.WithHTTPClient(&http.Client{
+			Transport: session,
+			Timeout:   httpClient.Timeout,
+			Jar:       httpClient.Jar,
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		client:  client,
+		address: address,
+		session: session,
+	}, nil
+}
+
+// Request sends a request to the Livebox.
+func (c *Client) Request(ctx context.Context, req *request.Request, out any) error {
+	return c.client.Request(ctx, req, out)
+}
+
+// Events returns the events of the channels.
+func (c *Client) Events(ctx context.Context, channels []string) <-chan *EventMessage {
+	events := make(chan *EventMessage)
+
+	go func() {
+		defer close(events)
+
+		for msg := range c.client.Events(ctx, channels) {
+			em := &EventMessage{Error: msg.Error}
+
+			if msg.Event != nil {
+				em.Event = &Event{
+					Handler:    msg.Event.Handler,
+					Reason:     msg.Event.Object.Reason,
+					Attributes: msg.Event.Object.Attributes,
+				}
+			}
+
+			select {
+			case events <- em:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events
+}
+
+// Session returns information about the session on the Livebox.
+func (c *Client) Session() SessionInfo {
+	info := c.session.info()
+	info.Address = c.address
+
+	return info
+}
+
+// sessionTransport is an http.RoundTripper that keeps track of the sessions
+// created on the Livebox.
+type sessionTransport struct {
+	next http.RoundTripper
+
+	mu        sync.Mutex
+	logins    uint64
+	lastLogin time.Time
+}
+
+// RoundTrip implements http.RoundTripper.
+func (st *sessionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := st.next.RoundTrip(req)
+
+	if err == nil && resp.StatusCode == http.StatusOK && req.Header.Get("Authorization") == "X-Sah-Login" {
+		st.mu.Lock()
+		st.logins++
+		st.lastLogin = time.Now()
+		st.mu.Unlock()
+	}
+
+	return resp, err
+}
+
+func (st *sessionTransport) info() SessionInfo {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	return SessionInfo{
+		Logins:    st.logins,
+		LastLogin: st.lastLogin,
+	}
+}
+
+// Middleware wraps an API, for example to cache, limit or instrument
+// requests.
+type Middleware func(next API) API
+
+// Chain returns the API wrapped by the middlewares. The first middleware is
+// the outermost one.
+func Chain(api API, middlewares ...Middleware) API {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		api = middlewares[i](api)
+	}
+
+	return api
+}
+
+// RequestFunc sends a request to the Livebox.
+type RequestFunc func(ctx context.Context, req *request.Request, out any) error
+
+// RequestMiddleware returns a Middleware that only wraps requests, events and
+// session information are left untouched.
+func RequestMiddleware(wrap func(next RequestFunc) RequestFunc) Middleware {
+	return func(next API) API {
+		return &requestAPI{API: next, request: wrap(next.Request)}
+	}
+}
+
+type requestAPI struct {
+	API
+	request RequestFunc
+}
+
+func (ra *requestAPI) Request(ctx context.Context, req *request.Request, out any) error {
+	return ra.request(ctx, req, out)
+}
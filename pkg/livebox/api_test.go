@@ -0,0 +1,47 @@
+package livebox
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSessionTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/denied" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	st := &sessionTransport{next: http.DefaultTransport}
+	client := &http.Client{Transport: st}
+
+	for _, tc := range []struct {
+		path, authorization string
+	}{
+		// A login.
+		{"/ws", "X-Sah-Login"},
+		// A request using the session.
+		{"/ws", "X-Sah 1234"},
+		// A failed login.
+		{"/denied", "X-Sah-Login"},
+	} {
+		req, err := http.NewRequest(http.MethodPost, server.URL+tc.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Set("Authorization", tc.authorization)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	if info := st.info(); info.Logins != 1 || info.LastLogin.IsZero() {
+		t.Errorf("session info = %+v, want 1 login", info)
+	}
+}
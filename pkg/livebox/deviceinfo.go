@@ -0,0 +1,31 @@
+package livebox
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Tomy2e/livebox-api-client/api/request"
+)
+
+// DeviceInfo identifies the Livebox.
+type DeviceInfo struct {
+	Manufacturer    string `json:"Manufacturer"`
+	ModelName       string `json:"ModelName"`
+	ProductClass    string `json:"ProductClass"`
+	SerialNumber    string `json:"SerialNumber"`
+	SoftwareVersion string `json:"SoftwareVersion"`
+	HardwareVersion string `json:"HardwareVersion"`
+}
+
+// GetDeviceInfo returns information identifying the Livebox.
+func GetDeviceInfo(ctx context.Context, client API) (*DeviceInfo, error) {
+	var deviceInfo struct {
+		Status DeviceInfo `json:"status"`
+	}
+
+	if err := client.Request(ctx, request.New("DeviceInfo", "get", nil), &deviceInfo); err != nil {
+		return nil, fmt.Errorf("failed to get device info: %w", err)
+	}
+
+	return &deviceInfo.Status, nil
+}
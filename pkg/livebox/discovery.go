@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/Tomy2e/livebox-api-client"
 	"github.com/Tomy2e/livebox-api-client/api/request"
 )
 
@@ -27,7 +26,7 @@ func (i *Interface) IsWLAN() bool {
 }
 
 // DiscoverInterfaces discovers network interfaces on the Livebox.
-func DiscoverInterfaces(ctx context.Context, client *livebox.Client) ([]*Interface, error) {
+func DiscoverInterfaces(ctx context.Context, client API) ([]*Interface, error) {
 	var mibs struct {
 		Status struct {
 			Base map[string]struct {
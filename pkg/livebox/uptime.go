@@ -0,0 +1,24 @@
+package livebox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Tomy2e/livebox-api-client/api/request"
+)
+
+// GetUptime returns the current uptime of the Livebox.
+func GetUptime(ctx context.Context, client API) (time.Duration, error) {
+	var deviceInfo struct {
+		Status struct {
+			UpTime float64 `json:"UpTime"`
+		} `json:"status"`
+	}
+
+	if err := client.Request(ctx, request.New("DeviceInfo", "get", nil), &deviceInfo); err != nil {
+		return 0, fmt.Errorf("failed to get uptime: %w", err)
+	}
+
+	return time.Duration(deviceInfo.Status.UpTime) * time.Second, nil
+}
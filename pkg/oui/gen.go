@@ -0,0 +1,89 @@
+//go:build ignore
+
+// This program generates the embedded OUI database from the IEEE registries.
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+var registries = []string{
+	"https://standards-oui.ieee.org/oui/oui.csv",
+	"https://standards-oui.ieee.org/oui28/mam.csv",
+	"https://standards-oui.ieee.org/oui36/oui36.csv",
+}
+
+func fetch(url string, vendors map[string]string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	r := csv.NewReader(resp.Body)
+	r.FieldsPerRecord = -1
+
+	// Skip header: Registry,Assignment,Organization Name,Organization Address.
+	if _, err := r.Read(); err != nil {
+		return err
+	}
+
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(record) < 3 {
+			continue
+		}
+
+		vendor := strings.Join(strings.Fields(record[2]), " ")
+		vendors[strings.ToUpper(record[1])] = vendor
+	}
+}
+
+func main() {
+	output := flag.String("o", "oui.txt", "Output file")
+	flag.Parse()
+
+	vendors := make(map[string]string)
+
+	for _, url := range registries {
+		if err := fetch(url, vendors); err != nil {
+			log.Fatalf("failed to fetch %s: %s", url, err)
+		}
+	}
+
+	prefixes := make([]string, 0, len(vendors))
+	for prefix := range vendors {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	var b strings.Builder
+	b.WriteString("# Code generated by gen.go from the IEEE registries. DO NOT EDIT.\n")
+
+	for _, prefix := range prefixes {
+		fmt.Fprintf(&b, "%s\t%s\n", prefix, vendors[prefix])
+	}
+
+	if err := os.WriteFile(*output, []byte(b.String()), 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,139 @@
+// Package oui maps MAC addresses to the vendor names registered in the IEEE
+// OUI database.
+package oui
+
+//go:generate go run gen.go -o oui.txt
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// prefixLengths are the lengths (in hex digits) of the MA-S, MA-M and MA-L
+// assignments, from the most to the least specific.
+var prefixLengths = []int{9, 7, 6}
+
+//go:embed oui.txt
+var embedded string
+
+// Database maps MAC address prefixes to vendor names.
+type Database struct {
+	vendors map[string]string
+}
+
+var defaultDatabase = sync.OnceValue(func() *Database {
+	db, err := Parse(strings.NewReader(embedded))
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded OUI database: %s", err))
+	}
+
+	return db
+})
+
+// Default returns the database embedded in the binary.
+func Default() *Database {
+	return defaultDatabase()
+}
+
+// Load loads a database from a file using the same format as the embedded
+// database.
+func Load(path string) (*Database, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open OUI database: %w", err)
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+// Parse parses a database. Each line contains an uppercase hex prefix and a
+// vendor name separated by a tab, lines starting with # are ignored.
+func Parse(r io.Reader) (*Database, error) {
+	db := &Database{vendors: make(map[string]string)}
+	scanner := bufio.NewScanner(r)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		prefix, vendor, ok := strings.Cut(text, "\t")
+		if !ok {
+			return nil, fmt.Errorf("line %d: missing tab separator", line)
+		}
+
+		if _, err := strconv.ParseUint(prefix, 16, 64); err != nil {
+			return nil, fmt.Errorf("line %d: invalid prefix: %s", line, prefix)
+		}
+
+		db.vendors[strings.ToUpper(prefix)] = vendor
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read OUI database: %w", err)
+	}
+
+	return db, nil
+}
+
+// Len returns the number of prefixes in the database.
+func (db *Database) Len() int {
+	return len(db.vendors)
+}
+
+// Lookup returns the vendor of a MAC address, or an empty string if the
+// vendor is unknown.
+func (db *Database) Lookup(mac string) string {
+	digits := normalize(mac)
+
+	for _, length := range prefixLengths {
+		if len(digits) < length {
+			continue
+		}
+
+		if vendor, ok := db.vendors[digits[:length]]; ok {
+			return vendor
+		}
+	}
+
+	return ""
+}
+
+// IsLocallyAdministered returns true if the MAC address is locally
+// administered. Such addresses are not registered to a vendor, they are
+// usually randomized by devices for privacy reasons.
+func IsLocallyAdministered(mac string) bool {
+	digits := normalize(mac)
+	if len(digits) < 2 {
+		return false
+	}
+
+	firstOctet, err := strconv.ParseUint(digits[:2], 16, 8)
+	if err != nil {
+		return false
+	}
+
+	return firstOctet&0x02 != 0
+}
+
+// normalize returns the uppercase hex digits of a MAC address.
+func normalize(mac string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9', r >= 'A' && r <= 'F':
+			return r
+		case r >= 'a' && r <= 'f':
+			return r - 'a' + 'A'
+		default:
+			return -1
+		}
+	}, mac)
+}
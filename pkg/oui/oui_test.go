@@ -0,0 +1,82 @@
+package oui
+
+import (
+	"strings"
+	"testing"
+)
+
+const testDatabase = `# Test database.
+001122	MA-L Vendor
+0011223	MA-M Vendor
+001122334	MA-S Vendor
+`
+
+func TestLookup(t *testing.T) {
+	db, err := Parse(strings.NewReader(testDatabase))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if db.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", db.Len())
+	}
+
+	tests := []struct {
+		mac  string
+		want string
+	}{
+		{mac: "00:11:22:33:44:55", want: "MA-S Vendor"},
+		{mac: "00:11:22:34:44:55", want: "MA-M Vendor"},
+		{mac: "00:11:22:40:00:00", want: "MA-L Vendor"},
+		{mac: "00-11-22-33-44-55", want: "MA-S Vendor"},
+		{mac: "00:11:22:3a:bc:de", want: "MA-M Vendor"},
+		{mac: "aa:bb:cc:dd:ee:ff", want: ""},
+		{mac: "00:11", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := db.Lookup(tt.mac); got != tt.want {
+			t.Errorf("Lookup(%q) = %q, want %q", tt.mac, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, database := range []string{"001122 Vendor\n", "XYZ\tVendor\n"} {
+		if _, err := Parse(strings.NewReader(database)); err == nil {
+			t.Errorf("Parse(%q) did not return an error", database)
+		}
+	}
+}
+
+func TestIsLocallyAdministered(t *testing.T) {
+	tests := []struct {
+		mac  string
+		want bool
+	}{
+		{mac: "00:11:22:33:44:55", want: false},
+		{mac: "02:00:00:00:00:01", want: true},
+		{mac: "DA:A1:19:00:00:01", want: true},
+		{mac: "f6:00:00:00:00:01", want: true},
+		{mac: "fc:00:00:00:00:01", want: false},
+		{mac: "", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := IsLocallyAdministered(tt.mac); got != tt.want {
+			t.Errorf("IsLocallyAdministered(%q) = %t, want %t", tt.mac, got, tt.want)
+		}
+	}
+}
+
+func TestDefault(t *testing.T) {
+	db := Default()
+	if db.Len() == 0 {
+		t.Skip("the embedded OUI database is empty, run go generate ./pkg/oui")
+	}
+
+	// 00:00:0C is the first OUI registered by Cisco.
+	if vendor := db.Lookup("00:00:0C:12:34:56"); !strings.Contains(vendor, "Cisco") {
+		t.Errorf("Lookup() = %q, want a Cisco vendor", vendor)
+	}
+}